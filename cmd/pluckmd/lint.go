@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [files...]",
+	Short: "Check the pluck directives in markdown docs without fetching anything",
+	Long: `Check the pluck directives in markdown docs without fetching anything.
+
+lint reports comments that look like pluck directives but are malformed,
+kinds that are not supported by the directive's lang, invalid start/end
+ranges, directives whose code block is never closed, and <!-- /pluck -->
+end markers that do not directly follow a directive or its code block.
+Directives without a code block are fine, since pluckmd creates the block.
+With no arguments, lint checks every markdown file under --dir, just like
+pluckmd does. Given file arguments, it only checks those files.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		files, err := markdownFiles(cfg, args)
+		if err != nil {
+			return err
+		}
+		return run.LintFiles(files)
+	},
+}
+
+func init() {
+	mainCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [files...]",
+	Short: "List the pluck directives in markdown docs without fetching anything",
+	Long: `List the pluck directives in markdown docs without fetching anything.
+
+With no arguments, list walks every markdown file under --dir, just like
+pluckmd does. Given file arguments, it only lists the directives in those
+files. Directives that cannot be parsed are reported as errors.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		files, err := markdownFiles(cfg, args)
+		if err != nil {
+			return err
+		}
+
+		// Print whatever could be parsed before reporting what could not.
+		occurrences, listErr := run.ListDirectives(files)
+		err = run.WriteDirectives(os.Stdout, listFormat, occurrences)
+		if err != nil {
+			return err
+		}
+		return listErr
+	},
+}
+
+var listFormat string
+
+func init() {
+	listCmd.Flags().StringVarP(
+		&listFormat,
+		"format",
+		"f",
+		run.ListTable,
+		"output format (table, json)",
+	)
+	mainCmd.AddCommand(listCmd)
+}
@@ -2,42 +2,152 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tahardi/pluckmd/internal/config"
+	"github.com/tahardi/pluckmd/internal/process"
 	"github.com/tahardi/pluckmd/internal/run"
 )
 
 const (
-	defaultDocDir  = "/must/provide/a/doc/dir/value"
-	defaultTimeout = 60
+	defaultDocDir = "/must/provide/a/doc/dir/value"
+	stdinArg      = "-"
+)
+
+var (
+	errStdinWithFiles  = errors.New("'-' cannot be combined with other files")
+	errStdinWithCheck  = errors.New("'-' cannot be combined with --check")
+	errStdinWithReport = errors.New("'-' cannot be combined with --report")
 )
 
 var mainCmd = &cobra.Command{
-	Use:          "pluckmd",
-	Short:        "CLI tool for downloading and inserting Go code into markdown docs",
-	SilenceUsage: true,
-	RunE: func(_ *cobra.Command, _ []string) error {
-		runner, err := run.NewRunner()
+	Use:   "pluckmd [files...]",
+	Short: "CLI tool for downloading and inserting Go code into markdown docs",
+	Long: `CLI tool for downloading and inserting Go code into markdown docs.
+
+With no arguments, pluckmd processes every markdown file under --dir. Given
+file arguments, it only processes those files. Given "-", it reads markdown
+from stdin and writes the processed markdown to stdout.`,
+	Args:          cobra.ArbitraryArgs,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		if report != "" {
+			err = run.ValidateReportFormat(report)
+			if err != nil {
+				return err
+			}
+		}
+
+		runner, err := run.NewRunner(cfg)
 		if err != nil {
 			return err
 		}
 
 		ctx, cancel := context.WithTimeout(
 			context.Background(),
-			time.Duration(timeout)*time.Second,
+			time.Duration(cfg.Timeout)*time.Second,
 		)
 		defer cancel()
-		return runner.Run(ctx, dir, ignoreDirs)
+
+		if slices.Contains(args, stdinArg) {
+			switch {
+			case len(args) > 1:
+				return errStdinWithFiles
+			case check:
+				return errStdinWithCheck
+			case report != "":
+				return errStdinWithReport
+			}
+			return runner.Filter(ctx, os.Stdin, os.Stdout)
+		}
+
+		files, err := markdownFiles(cfg, args)
+		if err != nil {
+			return err
+		}
+
+		var results []*run.FileResult
+		if check {
+			// The report says which blocks are out of date, so only print
+			// diffs when stdout is not taken by the report.
+			var diffOut io.Writer = os.Stdout
+			if report != "" {
+				diffOut = nil
+			}
+			results, err = runner.Check(ctx, files, diffOut)
+		} else {
+			results, err = runner.Run(ctx, files)
+		}
+
+		if report != "" {
+			reportErr := run.WriteReport(os.Stdout, report, results)
+			if reportErr != nil {
+				return reportErr
+			}
+		}
+		return err
 	},
 }
 
+var baseDir string
+var cacheDir string
+var cacheTTL time.Duration
+var check bool
+var configFile string
 var dir string
+var goPlucker string
 var ignoreDirs []string
+var include []string
+var jobs int
+var keepGoing bool
+var noGitignore bool
+var report string
 var timeout int
 
 func init() {
+	mainCmd.PersistentFlags().StringVar(
+		&baseDir,
+		"base-dir",
+		"",
+		"directory that relative local sources are resolved against (default: current directory)",
+	)
+	mainCmd.PersistentFlags().StringVar(
+		&cacheDir,
+		"cache-dir",
+		"",
+		"persist sources fetched from GitHub in this directory across runs (default: user cache dir when --cache-ttl is set)",
+	)
+	mainCmd.PersistentFlags().DurationVar(
+		&cacheTTL,
+		"cache-ttl",
+		0,
+		"how long persisted GitHub sources stay valid (default: 24h when --cache-dir is set)",
+	)
+	mainCmd.PersistentFlags().BoolVarP(
+		&check,
+		"check",
+		"c",
+		false,
+		"report out-of-date code blocks as a diff and fail instead of writing files",
+	)
+	mainCmd.PersistentFlags().StringVar(
+		&configFile,
+		"config",
+		"",
+		"path to config file (default: "+config.FileName+" in the current directory or a parent, up to the repo root)",
+	)
 	mainCmd.PersistentFlags().StringVarP(
 		&dir,
 		"dir",
@@ -45,24 +155,143 @@ func init() {
 		defaultDocDir,
 		"directory containing markdown files to process (recursive)",
 	)
+	mainCmd.PersistentFlags().StringVar(
+		&goPlucker,
+		"go-plucker",
+		config.NativeGoPlucker,
+		"how to pluck Go code: natively ("+config.NativeGoPlucker+") or with the pluck command ("+config.ExecGoPlucker+")",
+	)
 	mainCmd.PersistentFlags().StringSliceVarP(
 		&ignoreDirs,
 		"ignore-dir",
 		"i",
 		[]string{},
-		"directories to ignore (e.g., testdata/, .github/)",
+		"gitignore-style patterns of paths to ignore (e.g., testdata/, docs/**/draft-*.md)",
+	)
+	mainCmd.PersistentFlags().StringSliceVar(
+		&include,
+		"include",
+		[]string{config.DefaultInclude},
+		"gitignore-style patterns of markdown files to process (e.g., *.md, *.mdx)",
+	)
+	mainCmd.PersistentFlags().IntVarP(
+		&jobs,
+		"jobs",
+		"j",
+		config.DefaultJobs,
+		"number of markdown files to process in parallel",
+	)
+	mainCmd.PersistentFlags().BoolVarP(
+		&keepGoing,
+		"keep-going",
+		"k",
+		false,
+		"keep processing after a directive fails and report every failure at the end",
+	)
+	mainCmd.PersistentFlags().BoolVar(
+		&noGitignore,
+		"no-gitignore",
+		false,
+		"do not skip files matched by .gitignore files",
+	)
+	mainCmd.PersistentFlags().StringVar(
+		&report,
+		"report",
+		"",
+		"write a report on every directive to stdout in this format (json)",
 	)
 	mainCmd.PersistentFlags().IntVarP(
 		&timeout,
 		"timeout",
 		"t",
-		defaultTimeout,
+		config.DefaultTimeout,
 		"max allowed run time of pluckmd in seconds",
 	)
 }
 
+// loadConfig reads the config file, if there is one, and then overrides its
+// settings with any flags that were explicitly set on the command line.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	if configFile != "" {
+		cfg, err = config.Load(configFile)
+	} else {
+		cfg, err = config.FindAndLoad(".")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("base-dir") {
+		cfg.BaseDir = baseDir
+	}
+	if flags.Changed("dir") || len(cfg.Dirs) == 0 {
+		cfg.Dirs = []string{dir}
+	}
+	if flags.Changed("go-plucker") {
+		cfg.GoPlucker = goPlucker
+	}
+	if flags.Changed("include") {
+		cfg.Include = include
+	}
+	if flags.Changed("ignore-dir") {
+		cfg.Ignore = ignoreDirs
+	}
+	if flags.Changed("no-gitignore") {
+		cfg.Gitignore = !noGitignore
+	}
+	if flags.Changed("jobs") {
+		cfg.Jobs = jobs
+	}
+	if flags.Changed("keep-going") {
+		cfg.KeepGoing = keepGoing
+	}
+	if flags.Changed("timeout") {
+		cfg.Timeout = timeout
+	}
+	if flags.Changed("cache-dir") {
+		cfg.Cache.Dir = cacheDir
+	}
+	if flags.Changed("cache-ttl") {
+		cfg.Cache.TTL = cacheTTL
+	}
+	return cfg, nil
+}
+
+// markdownFiles returns the files given as arguments or, if there are none,
+// the markdown files found in the configured directories.
+func markdownFiles(cfg *config.Config, args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+	return run.ListMarkdownFilesInDirs(cfg.Dirs, run.ListOptions{
+		Include:   cfg.Include,
+		Exclude:   cfg.Ignore,
+		Gitignore: cfg.Gitignore,
+	})
+}
+
+// printError prints directive errors compiler-style (file:line:col: message)
+// so editors and CI annotations can jump straight to the directive.
+func printError(w io.Writer, err error) {
+	var diagnostics process.DiagnosticErrors
+	var diagnostic *process.DiagnosticError
+	switch {
+	case errors.As(err, &diagnostics):
+		fmt.Fprintln(w, diagnostics)
+		fmt.Fprintf(w, "Command failed: %d error(s)\n", len(diagnostics))
+	case errors.As(err, &diagnostic):
+		fmt.Fprintln(w, diagnostic)
+	default:
+		fmt.Fprintf(w, "Command failed: %v\n", err)
+	}
+}
+
 func main() {
 	if err := mainCmd.Execute(); err != nil {
-		fmt.Printf("Command failed: %v\n", err)
+		printError(os.Stderr, err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	DefaultDiskCacheTTL = 24 * time.Hour
+	DiskCacheDirName    = "pluckmd"
+	EntriesDirName      = "entries"
+	ObjectsDirName      = "objects"
+	EntryExt            = ".json"
+	DirPermissions      = 0700
+	FilePermissions     = 0600
+	ObjectPrefixLen     = 2
+
+	// PruneGracePeriod is how old an object must be before Prune may remove
+	// it, since another process may be about to write the entry that refers
+	// to it.
+	PruneGracePeriod = time.Minute
+)
+
+var (
+	ErrDiskCacher = errors.New("disk cacher")
+	ErrInvalidTTL = errors.New("invalid ttl")
+)
+
+// DiskCacher is a persistent Cacher that can be shared by multiple pluckmd
+// processes. Data is stored once per unique content under objects/, keyed by
+// its SHA-256 digest. Each URI maps to an entry under entries/ that records
+// the digest of its data along with when the entry expires. All files are
+// written to a temporary file and then renamed into place, so readers never
+// observe a partially written entry or object. Expired entries are removed
+// when they are read, and the cache is pruned when it is opened.
+type DiskCacher struct {
+	dir string
+	ttl time.Duration
+}
+
+type diskEntry struct {
+	URI     string    `json:"uri"`
+	Digest  string    `json:"digest"`
+	Expires time.Time `json:"expires"`
+}
+
+func NewDiskCacher() (*DiskCacher, error) {
+	dir, err := DefaultDiskCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewDiskCacherWithDirAndTTL(dir, DefaultDiskCacheTTL)
+}
+
+func NewDiskCacherWithDirAndTTL(dir string, ttl time.Duration) (*DiskCacher, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrInvalidTTL, ttl)
+	}
+
+	for _, sub := range []string{EntriesDirName, ObjectsDirName} {
+		err := os.MkdirAll(filepath.Join(dir, sub), DirPermissions)
+		if err != nil {
+			return nil, fmt.Errorf("%w: making cache dir: %w", ErrDiskCacher, err)
+		}
+	}
+
+	diskCacher := &DiskCacher{dir: dir, ttl: ttl}
+	err := diskCacher.Prune()
+	if err != nil {
+		return nil, err
+	}
+	return diskCacher, nil
+}
+
+func DefaultDiskCacheDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: getting user cache dir: %w", ErrDiskCacher, err)
+	}
+	return filepath.Join(userCacheDir, DiskCacheDirName), nil
+}
+
+func (d *DiskCacher) Dir() string {
+	return d.dir
+}
+
+func (d *DiskCacher) Store(ctx context.Context, uri string, data []byte) error {
+	return d.StoreWithTTL(ctx, uri, data, d.ttl)
+}
+
+func (d *DiskCacher) StoreWithTTL(
+	_ context.Context,
+	uri string,
+	data []byte,
+	ttl time.Duration,
+) error {
+	digest := Digest(data)
+	objectPath := d.objectPath(digest)
+
+	// Objects are content-addressed, so if one already exists it already
+	// holds exactly these bytes and there is no need to write it again. Touch
+	// it instead, so that Prune treats it like a new object.
+	now := time.Now()
+	err := os.Chtimes(objectPath, now, now)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = os.MkdirAll(filepath.Dir(objectPath), DirPermissions)
+		if err != nil {
+			return fmt.Errorf("%w: making object dir: %w", ErrDiskCacher, err)
+		}
+		err = WriteFileAtomic(objectPath, data)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: writing object: %w", ErrDiskCacher, err)
+	}
+
+	entry, err := json.Marshal(diskEntry{
+		URI:     uri,
+		Digest:  digest,
+		Expires: now.Add(ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: marshaling entry: %w", ErrDiskCacher, err)
+	}
+
+	err = WriteFileAtomic(d.entryPath(uri), entry)
+	if err != nil {
+		return fmt.Errorf("%w: writing entry: %w", ErrDiskCacher, err)
+	}
+	return nil
+}
+
+func (d *DiskCacher) Retrieve(_ context.Context, uri string) ([]byte, error) {
+	entryPath := d.entryPath(uri)
+	entry, err := readEntry(entryPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	case errors.Is(err, fs.ErrInvalid):
+		// Treat entries we cannot make sense of the same as missing entries.
+		// The caller will refetch the data and overwrite the entry.
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	case err != nil:
+		return nil, fmt.Errorf("%w: reading entry: %w", ErrDiskCacher, err)
+	case entry.URI != uri:
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	case time.Now().After(entry.Expires):
+		// The entry may already be gone if another process removed it.
+		_ = os.Remove(entryPath)
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	}
+
+	data, err := os.ReadFile(d.objectPath(entry.Digest))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	case err != nil:
+		return nil, fmt.Errorf("%w: reading object: %w", ErrDiskCacher, err)
+	case Digest(data) != entry.Digest:
+		return nil, fmt.Errorf("%w: %w: %s", ErrDiskCacher, ErrURINotFound, uri)
+	}
+	return data, nil
+}
+
+func (d *DiskCacher) Close() error {
+	return nil
+}
+
+// Prune removes expired and unreadable entries, and the objects that no entry
+// refers to anymore. Objects younger than PruneGracePeriod are kept.
+func (d *DiskCacher) Prune() error {
+	now := time.Now()
+	live := make(map[string]bool)
+
+	entriesDir := filepath.Join(d.dir, EntriesDirName)
+	entries, err := os.ReadDir(entriesDir)
+	if err != nil {
+		return fmt.Errorf("%w: reading entries: %w", ErrDiskCacher, err)
+	}
+	for _, dirEntry := range entries {
+		// Skip the temporary files of entries that are being written.
+		if filepath.Ext(dirEntry.Name()) != EntryExt {
+			continue
+		}
+
+		entryPath := filepath.Join(entriesDir, dirEntry.Name())
+		entry, err := readEntry(entryPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil || now.After(entry.Expires):
+			_ = os.Remove(entryPath)
+		default:
+			live[entry.Digest] = true
+		}
+	}
+
+	objectsDir := filepath.Join(d.dir, ObjectsDirName)
+	err = filepath.WalkDir(objectsDir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil || dirEntry.IsDir() || live[dirEntry.Name()] {
+			return err
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil || info.ModTime().After(now.Add(-PruneGracePeriod)) {
+			return nil
+		}
+		_ = os.Remove(path)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: pruning objects: %w", ErrDiskCacher, err)
+	}
+	return nil
+}
+
+// readEntry reads the entry at path. Entries that cannot be decoded are
+// reported as fs.ErrInvalid.
+func readEntry(path string) (*diskEntry, error) {
+	entryBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &diskEntry{}
+	err = json.Unmarshal(entryBytes, entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", fs.ErrInvalid, err)
+	}
+	return entry, nil
+}
+
+func (d *DiskCacher) entryPath(uri string) string {
+	return filepath.Join(d.dir, EntriesDirName, Digest([]byte(uri))+EntryExt)
+}
+
+func (d *DiskCacher) objectPath(digest string) string {
+	return filepath.Join(d.dir, ObjectsDirName, digest[:ObjectPrefixLen], digest)
+}
+
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory as
+// path and then renames it to path. Rename is atomic, so concurrent readers
+// see either the old file or the new one, never a partial write.
+func WriteFileAtomic(path string, data []byte) error {
+	return WriteFileAtomicWithPerm(path, data, FilePermissions)
+}
+
+// WriteFileAtomicWithPerm is like WriteFileAtomic, but the file gets perm
+// rather than FilePermissions.
+func WriteFileAtomicWithPerm(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Chmod(perm)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// G703 - potential path traversal. The caller chooses path, and the temp
+	// file is created next to it. So, we choose to suppress the lint error here.
+	//nolint:gosec
+	return os.Rename(tmp.Name(), path)
+}
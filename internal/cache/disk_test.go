@@ -0,0 +1,215 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/cache"
+)
+
+func TestDiskCacher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(t.TempDir(), time.Hour)
+		require.NoError(t, err)
+		uri := "https://example.com/data"
+		content := []byte("hello world")
+
+		// when
+		err = cacher.Store(ctx, uri, content)
+		require.NoError(t, err)
+		retrieved, err := cacher.Retrieve(ctx, uri)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, content, retrieved)
+	})
+
+	t.Run("happy path - shared between cachers", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		writer, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		reader, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		uri := "https://example.com/data"
+		content := []byte("hello world")
+
+		// when
+		err = writer.Store(ctx, uri, content)
+		require.NoError(t, err)
+		retrieved, err := reader.Retrieve(ctx, uri)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, content, retrieved)
+	})
+
+	t.Run("happy path - overwrite entry", func(t *testing.T) {
+		// given
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(t.TempDir(), time.Hour)
+		require.NoError(t, err)
+		uri := "https://example.com/data"
+		err = cacher.Store(ctx, uri, []byte("old"))
+		require.NoError(t, err)
+
+		// when
+		err = cacher.Store(ctx, uri, []byte("new"))
+		require.NoError(t, err)
+		retrieved, err := cacher.Retrieve(ctx, uri)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), retrieved)
+	})
+
+	t.Run("concurrent store and retrieve", func(t *testing.T) {
+		// given
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(t.TempDir(), time.Hour)
+		require.NoError(t, err)
+		numWorkers := 16
+
+		// when
+		var wg sync.WaitGroup
+		for i := range numWorkers {
+			wg.Go(func() {
+				uri := strconv.Itoa(i % 2)
+				assert.NoError(t, cacher.Store(ctx, uri, []byte(uri)))
+			})
+		}
+		wg.Wait()
+
+		// then
+		for _, uri := range []string{"0", "1"} {
+			got, retrieveErr := cacher.Retrieve(ctx, uri)
+			require.NoError(t, retrieveErr)
+			assert.Equal(t, []byte(uri), got)
+		}
+	})
+
+	t.Run("retrieve uri not found", func(t *testing.T) {
+		// given
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(t.TempDir(), time.Hour)
+		require.NoError(t, err)
+		uri := "non-existent"
+
+		// when
+		_, err = cacher.Retrieve(ctx, uri)
+
+		// then
+		require.ErrorIs(t, err, cache.ErrDiskCacher)
+		require.ErrorIs(t, err, cache.ErrURINotFound)
+		assert.Contains(t, err.Error(), uri)
+	})
+
+	t.Run("retrieve expired entry", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		uri := "expired"
+		err = cacher.StoreWithTTL(ctx, uri, []byte("value"), -time.Second)
+		require.NoError(t, err)
+
+		// when
+		_, err = cacher.Retrieve(ctx, uri)
+
+		// then
+		require.ErrorIs(t, err, cache.ErrURINotFound)
+		entries, err := os.ReadDir(filepath.Join(dir, cache.EntriesDirName))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("prune on open", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		err = cacher.Store(ctx, "live", []byte("live"))
+		require.NoError(t, err)
+		err = cacher.StoreWithTTL(ctx, "expired", []byte("expired"), -time.Second)
+		require.NoError(t, err)
+
+		old := time.Now().Add(-2 * cache.PruneGracePeriod)
+		for _, data := range []string{"live", "expired"} {
+			digest := cache.Digest([]byte(data))
+			objectPath := filepath.Join(dir, cache.ObjectsDirName, digest[:2], digest)
+			require.NoError(t, os.Chtimes(objectPath, old, old))
+		}
+
+		// when
+		_, err = cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+
+		// then
+		entries, err := os.ReadDir(filepath.Join(dir, cache.EntriesDirName))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		liveDigest := cache.Digest([]byte("live"))
+		expiredDigest := cache.Digest([]byte("expired"))
+		assert.FileExists(t, filepath.Join(dir, cache.ObjectsDirName, liveDigest[:2], liveDigest))
+		assert.NoFileExists(t, filepath.Join(dir, cache.ObjectsDirName, expiredDigest[:2], expiredDigest))
+
+		retrieved, err := cacher.Retrieve(ctx, "live")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("live"), retrieved)
+	})
+
+	t.Run("prune keeps new objects", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		err = cacher.StoreWithTTL(ctx, "expired", []byte("expired"), -time.Second)
+		require.NoError(t, err)
+
+		// when
+		err = cacher.Prune()
+		require.NoError(t, err)
+
+		// then
+		digest := cache.Digest([]byte("expired"))
+		assert.FileExists(t, filepath.Join(dir, cache.ObjectsDirName, digest[:2], digest))
+	})
+
+	t.Run("retrieve corrupted object", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		cacher, err := cache.NewDiskCacherWithDirAndTTL(dir, time.Hour)
+		require.NoError(t, err)
+		uri := "corrupted"
+		data := []byte("value")
+		err = cacher.Store(ctx, uri, data)
+		require.NoError(t, err)
+
+		digest := cache.Digest(data)
+		objectPath := filepath.Join(dir, cache.ObjectsDirName, digest[:2], digest)
+		err = os.WriteFile(objectPath, []byte("garbage"), 0600)
+		require.NoError(t, err)
+
+		// when
+		_, err = cacher.Retrieve(ctx, uri)
+
+		// then
+		require.ErrorIs(t, err, cache.ErrURINotFound)
+	})
+
+	t.Run("error - invalid ttl", func(t *testing.T) {
+		// when
+		_, err := cache.NewDiskCacherWithDirAndTTL(t.TempDir(), 0)
+
+		// then
+		require.ErrorIs(t, err, cache.ErrInvalidTTL)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 )
 
 var (
@@ -12,6 +13,7 @@ var (
 )
 
 type RAMCacher struct {
+	mu    sync.RWMutex
 	vault map[string][]byte
 }
 
@@ -24,11 +26,17 @@ func NewRAMCacherWithVault(vault map[string][]byte) (*RAMCacher, error) {
 }
 
 func (r *RAMCacher) Store(_ context.Context, uri string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.vault[uri] = data
 	return nil
 }
 
 func (r *RAMCacher) Retrieve(_ context.Context, uri string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	data, ok := r.vault[uri]
 	if ok {
 		return data, nil
@@ -37,6 +45,9 @@ func (r *RAMCacher) Retrieve(_ context.Context, uri string) ([]byte, error) {
 }
 
 func (r *RAMCacher) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.vault = map[string][]byte{}
 	return nil
 }
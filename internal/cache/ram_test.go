@@ -2,6 +2,8 @@ package cache_test
 
 import (
 	"context"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,4 +83,34 @@ func TestRAMCacher(t *testing.T) {
 		require.NoError(t, retrieveErr)
 		assert.Equal(t, []byte("initial"), data)
 	})
+
+	t.Run("concurrent store and retrieve", func(t *testing.T) {
+		// given
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		numWorkers := 16
+
+		// when
+		storeErrs := make([]error, numWorkers)
+		retrieveErrs := make([]error, numWorkers)
+		var wg sync.WaitGroup
+		for i := range numWorkers {
+			wg.Go(func() {
+				uri := strconv.Itoa(i)
+				storeErrs[i] = cacher.Store(ctx, uri, []byte(uri))
+				_, retrieveErrs[i] = cacher.Retrieve(ctx, uri)
+			})
+		}
+		wg.Wait()
+
+		// then
+		for i := range numWorkers {
+			require.NoError(t, storeErrs[i])
+			require.NoError(t, retrieveErrs[i])
+			uri := strconv.Itoa(i)
+			got, retrieveErr := cacher.Retrieve(ctx, uri)
+			require.NoError(t, retrieveErr)
+			assert.Equal(t, []byte(uri), got)
+		}
+	})
 }
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tahardi/pluckmd/internal/fetch"
+	"github.com/tahardi/pluckmd/internal/pluck"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FileName       = ".pluckmd.yaml"
+	GitDirName     = ".git"
+	DefaultInclude = "*.md"
+	DefaultJobs    = 1
+	DefaultTimeout = 60
+	GitHubFetcher  = fetch.GitHubFetcherName
+	LocalFetcher   = fetch.LocalFetcherName
+
+	// NativeGoPlucker and ExecGoPlucker select pluck.GoPlucker and
+	// pluck.ExecGoPlucker respectively.
+	NativeGoPlucker = "native"
+	ExecGoPlucker   = "exec"
+)
+
+var (
+	ErrConfig           = errors.New("config")
+	ErrConfigNotFound   = errors.New("config file not found")
+	ErrUnknownFetcher   = errors.New("unknown fetcher")
+	ErrUnknownLang      = errors.New("unknown lang")
+	ErrUnknownGoPlucker = errors.New("unknown go plucker")
+	ErrInvalidAliasName = errors.New("invalid alias name")
+)
+
+// Config holds the project settings read from a .pluckmd.yaml file. Relative
+// paths are resolved against the directory containing the config file.
+type Config struct {
+	Dirs      []string          `yaml:"dirs"`
+	BaseDir   string            `yaml:"baseDir"`
+	Include   []string          `yaml:"include"`
+	Ignore    []string          `yaml:"ignore"`
+	Gitignore bool              `yaml:"gitignore"`
+	Timeout   int               `yaml:"timeout"`
+	Jobs      int               `yaml:"jobs"`
+	KeepGoing bool              `yaml:"keepGoing"`
+	Fetchers  []string          `yaml:"fetchers"`
+	Langs     []pluck.Lang      `yaml:"langs"`
+	GoPlucker string            `yaml:"goPlucker"`
+	Cache     CacheConfig       `yaml:"cache"`
+	Aliases   map[string]string `yaml:"aliases"`
+}
+
+type CacheConfig struct {
+	Dir string        `yaml:"dir"`
+	TTL time.Duration `yaml:"ttl"`
+}
+
+func Default() *Config {
+	return &Config{
+		Dirs:      nil,
+		BaseDir:   "",
+		Include:   []string{DefaultInclude},
+		Ignore:    nil,
+		Gitignore: true,
+		Timeout:   DefaultTimeout,
+		Jobs:      DefaultJobs,
+		KeepGoing: false,
+		Fetchers:  []string{GitHubFetcher, LocalFetcher},
+		Langs:     []pluck.Lang{pluck.Go, pluck.YAML},
+		GoPlucker: NativeGoPlucker,
+		Cache:     CacheConfig{Dir: "", TTL: 0},
+		Aliases:   map[string]string{},
+	}
+}
+
+// Find looks for a config file in dir and each of its parents, stopping at
+// the repository root (the first directory containing .git). It returns
+// ErrConfigNotFound if there is no config file.
+func Find(dir string) (string, error) {
+	// Walk up using relative paths so that the paths we resolve against the
+	// config file's directory stay relative too. We only need the absolute
+	// path to know when we have reached the filesystem root.
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: getting absolute path: %w", ErrConfig, err)
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err = os.Stat(path); err == nil {
+			return path, nil
+		}
+
+		_, err = os.Stat(filepath.Join(dir, GitDirName))
+		if err == nil || filepath.Dir(abs) == abs {
+			return "", fmt.Errorf("%w: %w", ErrConfig, ErrConfigNotFound)
+		}
+		dir = filepath.Join(dir, "..")
+		abs = filepath.Dir(abs)
+	}
+}
+
+// Load reads the config file at path. Fields that are not set in the file
+// keep their default values, except for the base dir, which defaults to the
+// directory of the config file so that local sources resolve the same way
+// wherever pluckmd is run from.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading file: %w", ErrConfig, err)
+	}
+
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BaseDir == "" {
+		cfg.BaseDir = "."
+	}
+	cfg.ResolvePaths(filepath.Dir(path))
+	return cfg, nil
+}
+
+// FindAndLoad loads the config file found by Find. If there is no config
+// file, it returns the default config.
+func FindAndLoad(dir string) (*Config, error) {
+	path, err := Find(dir)
+	switch {
+	case errors.Is(err, ErrConfigNotFound):
+		return Default(), nil
+	case err != nil:
+		return nil, err
+	}
+	return Load(path)
+}
+
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: decoding: %w", ErrConfig, err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	for _, fetcher := range c.Fetchers {
+		if fetcher != GitHubFetcher && fetcher != LocalFetcher {
+			return fmt.Errorf("%w: %w: %s", ErrConfig, ErrUnknownFetcher, fetcher)
+		}
+	}
+
+	for _, lang := range c.Langs {
+		if !lang.Valid() {
+			return fmt.Errorf("%w: %w: %s", ErrConfig, ErrUnknownLang, lang)
+		}
+	}
+
+	if c.GoPlucker != NativeGoPlucker && c.GoPlucker != ExecGoPlucker {
+		return fmt.Errorf("%w: %w: %s", ErrConfig, ErrUnknownGoPlucker, c.GoPlucker)
+	}
+
+	for name := range c.Aliases {
+		if name == "" || strings.ContainsAny(name, "/@") {
+			return fmt.Errorf("%w: %w: '%s'", ErrConfig, ErrInvalidAliasName, name)
+		}
+	}
+	return nil
+}
+
+// ResolvePaths makes relative doc dirs, base dir, and cache dir relative to
+// baseDir.
+func (c *Config) ResolvePaths(baseDir string) {
+	for i, dir := range c.Dirs {
+		c.Dirs[i] = resolvePath(baseDir, dir)
+	}
+	if c.BaseDir != "" {
+		c.BaseDir = resolvePath(baseDir, c.BaseDir)
+	}
+	if c.Cache.Dir != "" {
+		c.Cache.Dir = resolvePath(baseDir, c.Cache.Dir)
+	}
+}
+
+func resolvePath(baseDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
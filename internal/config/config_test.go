@@ -0,0 +1,159 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/config"
+	"github.com/tahardi/pluckmd/internal/pluck"
+)
+
+const configYAML = `dirs:
+  - docs
+baseDir: src
+include:
+  - "*.md"
+  - "*.mdx"
+ignore:
+  - testdata/
+gitignore: false
+timeout: 120
+jobs: 4
+fetchers:
+  - local
+langs:
+  - yaml
+goPlucker: exec
+cache:
+  dir: .cache
+  ttl: 12h
+aliases:
+  pluckmd: https://github.com/tahardi/pluckmd/blob/main
+`
+
+func TestParse(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// when
+		cfg, err := config.Parse([]byte(configYAML))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"docs"}, cfg.Dirs)
+		assert.Equal(t, "src", cfg.BaseDir)
+		assert.Equal(t, []string{"*.md", "*.mdx"}, cfg.Include)
+		assert.Equal(t, []string{"testdata/"}, cfg.Ignore)
+		assert.False(t, cfg.Gitignore)
+		assert.Equal(t, 120, cfg.Timeout)
+		assert.Equal(t, 4, cfg.Jobs)
+		assert.Equal(t, []string{config.LocalFetcher}, cfg.Fetchers)
+		assert.Equal(t, []pluck.Lang{pluck.YAML}, cfg.Langs)
+		assert.Equal(t, config.ExecGoPlucker, cfg.GoPlucker)
+		assert.Equal(t, ".cache", cfg.Cache.Dir)
+		assert.Equal(t, 12*time.Hour, cfg.Cache.TTL)
+		assert.Equal(t, "https://github.com/tahardi/pluckmd/blob/main", cfg.Aliases["pluckmd"])
+	})
+
+	t.Run("happy path - empty uses defaults", func(t *testing.T) {
+		// when
+		cfg, err := config.Parse([]byte{})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, config.Default(), cfg)
+	})
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr error
+	}{
+		{
+			name:    "error - unknown field",
+			yaml:    "not_a_field: true\n",
+			wantErr: config.ErrConfig,
+		},
+		{
+			name:    "error - unknown fetcher",
+			yaml:    "fetchers: [gitlab]\n",
+			wantErr: config.ErrUnknownFetcher,
+		},
+		{
+			name:    "error - unknown lang",
+			yaml:    "langs: [rust]\n",
+			wantErr: config.ErrUnknownLang,
+		},
+		{
+			name:    "error - unknown go plucker",
+			yaml:    "goPlucker: wasm\n",
+			wantErr: config.ErrUnknownGoPlucker,
+		},
+		{
+			name:    "error - invalid alias name",
+			yaml:    "aliases:\n  a/b: https://github.com\n",
+			wantErr: config.ErrInvalidAliasName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse([]byte(tt.yaml))
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFindAndLoad(t *testing.T) {
+	t.Run("happy path - config in parent dir", func(t *testing.T) {
+		// given
+		root := t.TempDir()
+		nested := filepath.Join(root, "a", "b")
+		require.NoError(t, os.MkdirAll(nested, 0700))
+		err := os.WriteFile(filepath.Join(root, config.FileName), []byte(configYAML), 0600)
+		require.NoError(t, err)
+
+		// when
+		cfg, err := config.FindAndLoad(nested)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(nested, "..", "..", "docs")}, cfg.Dirs)
+		assert.Equal(t, filepath.Join(nested, "..", "..", "src"), cfg.BaseDir)
+		assert.Equal(t, filepath.Join(nested, "..", "..", ".cache"), cfg.Cache.Dir)
+	})
+
+	t.Run("happy path - base dir defaults to config dir", func(t *testing.T) {
+		// given
+		root := t.TempDir()
+		nested := filepath.Join(root, "sub")
+		require.NoError(t, os.MkdirAll(nested, 0700))
+		err := os.WriteFile(filepath.Join(root, config.FileName), []byte("dirs: [docs]\n"), 0600)
+		require.NoError(t, err)
+
+		// when
+		cfg, err := config.FindAndLoad(nested)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(nested, ".."), cfg.BaseDir)
+	})
+
+	t.Run("happy path - stops at repo root", func(t *testing.T) {
+		// given
+		root := t.TempDir()
+		repo := filepath.Join(root, "repo")
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, config.GitDirName), 0700))
+		err := os.WriteFile(filepath.Join(root, config.FileName), []byte(configYAML), 0600)
+		require.NoError(t, err)
+
+		// when
+		cfg, err := config.FindAndLoad(repo)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, config.Default(), cfg)
+	})
+}
@@ -0,0 +1,77 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AliasPrefix    = "@"
+	AliasSeparator = "/"
+)
+
+var (
+	ErrAliasFetcher = errors.New("alias fetcher")
+	ErrUnknownAlias = errors.New("unknown alias")
+)
+
+// AliasFetcher expands source aliases before handing the URI to the wrapped
+// fetcher. For example, given the alias "pluckmd" for
+// "https://github.com/tahardi/pluckmd/blob/main", the URI
+// "@pluckmd/internal/pluck/goplucker.go" is fetched as
+// "https://github.com/tahardi/pluckmd/blob/main/internal/pluck/goplucker.go".
+type AliasFetcher struct {
+	aliases map[string]string
+	fetcher Fetcher
+}
+
+func NewAliasFetcher(
+	aliases map[string]string,
+	fetcher Fetcher,
+) (*AliasFetcher, error) {
+	return &AliasFetcher{aliases: aliases, fetcher: fetcher}, nil
+}
+
+// Name returns the name of the wrapped fetcher.
+func (a *AliasFetcher) Name() string {
+	return Name(a.fetcher)
+}
+
+func (a *AliasFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	data, _, err := a.FetchCached(ctx, uri)
+	return data, err
+}
+
+// FetchCached fetches uri like Fetch, and reports whether the wrapped fetcher
+// served it from its cache.
+func (a *AliasFetcher) FetchCached(
+	ctx context.Context,
+	uri string,
+) ([]byte, bool, error) {
+	expanded, err := ExpandAlias(a.aliases, uri)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrAliasFetcher, err)
+	}
+	return Cached(ctx, a.fetcher, expanded)
+}
+
+// ExpandAlias replaces a leading "@name" in uri with the value of the alias
+// called name. URIs that do not start with "@" are returned unchanged.
+func ExpandAlias(aliases map[string]string, uri string) (string, error) {
+	if !strings.HasPrefix(uri, AliasPrefix) {
+		return uri, nil
+	}
+
+	name, rest, _ := strings.Cut(strings.TrimPrefix(uri, AliasPrefix), AliasSeparator)
+	target, ok := aliases[name]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownAlias, name)
+	}
+
+	if rest == "" {
+		return target, nil
+	}
+	return strings.TrimSuffix(target, AliasSeparator) + AliasSeparator + rest, nil
+}
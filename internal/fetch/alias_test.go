@@ -0,0 +1,97 @@
+package fetch_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/fetch"
+)
+
+func TestAliasFetcher_Fetch(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		want := localGo
+		aliases := map[string]string{"fetch": "."}
+		local, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+		fetcher, err := fetch.NewAliasFetcher(aliases, local)
+		require.NoError(t, err)
+
+		// when
+		got, err := fetcher.Fetch(ctx, "@fetch/local.go")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("error - unknown alias", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		local, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+		fetcher, err := fetch.NewAliasFetcher(map[string]string{}, local)
+		require.NoError(t, err)
+
+		// when
+		_, err = fetcher.Fetch(ctx, "@fetch/local.go")
+
+		// then
+		require.ErrorIs(t, err, fetch.ErrUnknownAlias)
+	})
+}
+
+func TestExpandAlias(t *testing.T) {
+	aliases := map[string]string{
+		"pluckmd": "https://github.com/tahardi/pluckmd/blob/main",
+		"slash":   "https://github.com/tahardi/pluckmd/blob/main/",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "No alias",
+			input:    "internal/pluck/goplucker.go",
+			expected: "internal/pluck/goplucker.go",
+		},
+		{
+			name:     "Alias with path",
+			input:    "@pluckmd/internal/pluck/goplucker.go",
+			expected: "https://github.com/tahardi/pluckmd/blob/main/internal/pluck/goplucker.go",
+		},
+		{
+			name:     "Alias with trailing slash",
+			input:    "@slash/README.md",
+			expected: "https://github.com/tahardi/pluckmd/blob/main/README.md",
+		},
+		{
+			name:     "Alias only",
+			input:    "@pluckmd",
+			expected: "https://github.com/tahardi/pluckmd/blob/main",
+		},
+		{
+			name:    "Unknown alias",
+			input:   "@unknown/file.go",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetch.ExpandAlias(aliases, tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, fetch.ErrUnknownAlias)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tahardi/pluckmd/internal/cache"
+)
+
+var (
+	ErrCachingFetcher = errors.New("caching fetcher")
+)
+
+// CachingFetcher stores what the wrapped fetcher fetches in a cacher, keyed by
+// URI, and serves later fetches of the same URI from it. Wrap it in an
+// AliasFetcher so that entries are keyed by the expanded URI rather than by
+// an alias, which may mean something else in another repo.
+type CachingFetcher struct {
+	cacher  cache.Cacher
+	fetcher Fetcher
+}
+
+func NewCachingFetcher(
+	cacher cache.Cacher,
+	fetcher Fetcher,
+) (*CachingFetcher, error) {
+	return &CachingFetcher{cacher: cacher, fetcher: fetcher}, nil
+}
+
+// Name returns the name of the wrapped fetcher.
+func (c *CachingFetcher) Name() string {
+	return Name(c.fetcher)
+}
+
+func (c *CachingFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	data, _, err := c.FetchCached(ctx, uri)
+	return data, err
+}
+
+// FetchCached fetches uri like Fetch, and reports whether it was served from
+// the cacher.
+func (c *CachingFetcher) FetchCached(
+	ctx context.Context,
+	uri string,
+) ([]byte, bool, error) {
+	data, err := c.cacher.Retrieve(ctx, uri)
+	switch {
+	case err == nil:
+		return data, true, nil
+	case errors.Is(err, cache.ErrURINotFound):
+		break
+	default:
+		return nil, false, fmt.Errorf("%w: retrieving: %w", ErrCachingFetcher, err)
+	}
+
+	data, err = c.fetcher.Fetch(ctx, uri)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = c.cacher.Store(ctx, uri, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: storing: %w", ErrCachingFetcher, err)
+	}
+	return data, false, nil
+}
@@ -0,0 +1,117 @@
+package fetch_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/cache"
+	"github.com/tahardi/pluckmd/internal/fetch"
+	"github.com/tahardi/pluckmd/internal/mocks"
+)
+
+func TestCachingFetcher_Fetch(t *testing.T) {
+	uri := "https://github.com/tahardi/pluckmd/blob/main/go.mod"
+	data := []byte("module github.com/tahardi/pluckmd\n")
+
+	t.Run("happy path - fetches and stores", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		wrapped := mocks.NewFetcher(t)
+		wrapped.On("Fetch", ctx, uri).Return(data, nil).Once()
+		fetcher, err := fetch.NewCachingFetcher(cacher, wrapped)
+		require.NoError(t, err)
+
+		// when
+		got, hit, err := fetcher.FetchCached(ctx, uri)
+		require.NoError(t, err)
+		cached, err := cacher.Retrieve(ctx, uri)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+		assert.False(t, hit)
+		assert.Equal(t, data, cached)
+	})
+
+	t.Run("happy path - served from cacher", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		err = cacher.Store(ctx, uri, data)
+		require.NoError(t, err)
+		wrapped := mocks.NewFetcher(t)
+		fetcher, err := fetch.NewCachingFetcher(cacher, wrapped)
+		require.NoError(t, err)
+
+		// when
+		got, hit, err := fetcher.FetchCached(ctx, uri)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+		assert.True(t, hit)
+	})
+
+	t.Run("happy path - through alias fetcher", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		err = cacher.Store(ctx, uri, data)
+		require.NoError(t, err)
+		caching, err := fetch.NewCachingFetcher(cacher, mocks.NewFetcher(t))
+		require.NoError(t, err)
+		aliases := map[string]string{"pluckmd": "https://github.com/tahardi/pluckmd/blob/main"}
+		fetcher, err := fetch.NewAliasFetcher(aliases, caching)
+		require.NoError(t, err)
+
+		// when
+		got, hit, err := fetch.Cached(ctx, fetcher, "@pluckmd/go.mod")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+		assert.True(t, hit)
+	})
+
+	t.Run("error - cacher", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher := mocks.NewCacher(t)
+		cacher.On("Retrieve", ctx, uri).Return(nil, assert.AnError)
+		wrapped := mocks.NewFetcher(t)
+		fetcher, err := fetch.NewCachingFetcher(cacher, wrapped)
+		require.NoError(t, err)
+
+		// when
+		_, err = fetcher.Fetch(ctx, uri)
+
+		// then
+		require.ErrorIs(t, err, fetch.ErrCachingFetcher)
+		require.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("error - fetcher", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		wrapped := mocks.NewFetcher(t)
+		wrapped.On("Fetch", ctx, uri).Return(nil, assert.AnError)
+		fetcher, err := fetch.NewCachingFetcher(cacher, wrapped)
+		require.NoError(t, err)
+
+		// when
+		_, err = fetcher.Fetch(ctx, uri)
+
+		// then
+		require.ErrorIs(t, err, assert.AnError)
+		_, err = cacher.Retrieve(ctx, uri)
+		require.ErrorIs(t, err, cache.ErrURINotFound)
+	})
+}
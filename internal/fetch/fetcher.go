@@ -1,7 +1,52 @@
 package fetch
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	GitHubFetcherName = "github"
+	LocalFetcherName  = "local"
+)
 
 type Fetcher interface {
 	Fetch(ctx context.Context, uri string) (data []byte, err error)
 }
+
+// Namer is implemented by fetchers that can report their name, e.g., so that
+// a run report can say which fetcher resolved a source.
+type Namer interface {
+	Name() string
+}
+
+// CacheFetcher is implemented by fetchers that keep a cache of what they
+// fetch, so that a run report can say whether a source came from it.
+type CacheFetcher interface {
+	Fetcher
+	FetchCached(ctx context.Context, uri string) (data []byte, cached bool, err error)
+}
+
+// Cached fetches uri with fetcher and reports whether the data came from the
+// fetcher's cache. Fetchers that do not implement CacheFetcher never report a
+// cache hit.
+func Cached(
+	ctx context.Context,
+	fetcher Fetcher,
+	uri string,
+) ([]byte, bool, error) {
+	if cacheFetcher, ok := fetcher.(CacheFetcher); ok {
+		return cacheFetcher.FetchCached(ctx, uri)
+	}
+	data, err := fetcher.Fetch(ctx, uri)
+	return data, false, err
+}
+
+// Name returns the name of fetcher if it implements Namer, or its type
+// otherwise.
+func Name(fetcher Fetcher) string {
+	if namer, ok := fetcher.(Namer); ok {
+		return namer.Name()
+	}
+	return fmt.Sprintf("%T", fetcher)
+}
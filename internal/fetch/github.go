@@ -34,6 +34,10 @@ func NewGitHubFetcherWithClient(client *http.Client) (*GitHubFetcher, error) {
 	return &GitHubFetcher{client: client}, nil
 }
 
+func (g *GitHubFetcher) Name() string {
+	return GitHubFetcherName
+}
+
 func (g *GitHubFetcher) Fetch(
 	ctx context.Context,
 	uri string,
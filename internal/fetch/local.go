@@ -28,6 +28,10 @@ func NewLocalFetcherWithBaseDir(baseDir string) (*LocalFletcher, error) {
 	return &LocalFletcher{baseDir: baseDir}, nil
 }
 
+func (l *LocalFletcher) Name() string {
+	return LocalFetcherName
+}
+
 func (l *LocalFletcher) Fetch(
 	_ context.Context,
 	uri string,
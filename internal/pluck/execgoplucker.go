@@ -0,0 +1,77 @@
+package pluck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	GoPluckCmd       = "pluck"
+	GoPluckCLISource = "github.com/blocky/pluck/cmd/pluck@v0.1.1"
+	PickArg          = "--pick"
+)
+
+var (
+	ErrExecGoPlucker    = errors.New("exec go plucker")
+	ErrPluckCmdNotFound = fmt.Errorf("pluck command '%s' not found", GoPluckCmd)
+)
+
+// ExecGoPlucker plucks Go code by running the pluck command. GoPlucker
+// produces the same output without it.
+type ExecGoPlucker struct{}
+
+func NewExecGoPlucker() (*ExecGoPlucker, error) {
+	_, err := exec.LookPath(GoPluckCmd)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: %w: install '%s' via 'go install %s'",
+			ErrExecGoPlucker,
+			ErrPluckCmdNotFound,
+			GoPluckCmd,
+			GoPluckCLISource,
+		)
+	}
+	return &ExecGoPlucker{}, nil
+}
+
+func (e *ExecGoPlucker) Pluck(
+	ctx context.Context,
+	code string,
+	name string,
+	kind Kind,
+) (string, error) {
+	switch kind {
+	case File:
+		return code, nil
+	case Func, Type:
+		break
+	case Const, Var, Signature, Node:
+		return "", fmt.Errorf("%w: %s kind not supported", ErrExecGoPlucker, kind)
+	default:
+		return "", fmt.Errorf("%w: unrecognized kind: %v", ErrExecGoPlucker, kind)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	pick := fmt.Sprintf("%s=%s:%s", PickArg, kind, name)
+
+	cmd := exec.CommandContext(ctx, GoPluckCmd, pick)
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf(
+			"%w: running %s: %s",
+			ErrExecGoPlucker,
+			GoPluckCmd,
+			stderr.String(),
+		)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,75 @@
+package pluck_test
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/pluck"
+)
+
+func TestExecGoPlucker_Pluck(t *testing.T) {
+	_, err := exec.LookPath(pluck.GoPluckCmd)
+	if err != nil {
+		t.Skipf("%s not installed", pluck.GoPluckCmd)
+	}
+
+	tests := []struct {
+		name   string
+		kind   pluck.Kind
+		source string
+	}{
+		{name: goPlucker, kind: pluck.Type, source: goPluckerSource},
+		{name: goPluckerPluck, kind: pluck.Func, source: goPluckerSource},
+		{name: "Stack", kind: pluck.Type, source: genericSource},
+		{name: "Pair", kind: pluck.Type, source: genericSource},
+		{name: "ID", kind: pluck.Type, source: genericSource},
+		{name: "Set", kind: pluck.Type, source: genericSource},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - same as GoPlucker - "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			execPlucker, err := pluck.NewExecGoPlucker()
+			require.NoError(t, err)
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+			want, err := plucker.Pluck(ctx, tt.source, tt.name, tt.kind)
+			require.NoError(t, err)
+
+			// when
+			got, err := execPlucker.Pluck(ctx, tt.source, tt.name, tt.kind)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		})
+	}
+
+	t.Run("error - method of generic type", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewExecGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, genericSource, "Stack.Push", pluck.Func)
+
+		// then
+		require.ErrorIs(t, err, pluck.ErrExecGoPlucker)
+	})
+
+	t.Run("error - type/func not in code", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewExecGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, goPluckerSource, "funcDoesNotExist", pluck.Func)
+
+		// then
+		require.Error(t, err)
+	})
+}
@@ -5,34 +5,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
 	"strings"
 )
 
 const (
-	GoPluckCmd       = "pluck"
-	GoPluckCLISource = "github.com/blocky/pluck/cmd/pluck@v0.1.1"
-	PickArg          = "--pick"
+	NameSeparator = "."
+	GroupPrefix   = "("
+	GroupSuffix   = ")"
 )
 
 var (
-	ErrGoPlucker        = errors.New("go plucker")
-	ErrPluckCmdNotFound = fmt.Errorf("pluck command '%s' not found", GoPluckCmd)
+	ErrGoPlucker    = errors.New("go plucker")
+	ErrDeclNotFound = errors.New("declaration not found")
+	ErrImplicitSpec = errors.New("implicit spec")
 )
 
+// GoPlucker plucks Go code by parsing it with go/parser. Functions are named
+// "Func", methods "Type.Method", even if Type is generic, and types "Type".
+// Consts and vars are named like types, which selects the single spec that
+// declares the name, or "(Name)", which selects the whole declaration that
+// the spec is part of, e.g., a parenthesised iota group. Snippets are the
+// source text of the declaration, from its keyword to its end, without doc
+// comments unless plucked with PluckWithDoc. Struct fields and interface
+// methods are named "Type.member", or "Type.member.member" for fields of
+// nested struct types, and come with their tag and line comment, but without
+// the indentation they have inside the type.
+//
+// Signatures of functions, methods, interface methods, and types are named
+// like those. They are printed with go/printer on a single line, without
+// bodies or field lists, e.g., "func (g *GoPlucker) Pluck(ctx
+// context.Context, ...) (string, error)" or "type GoPlucker struct".
 type GoPlucker struct{}
 
 func NewGoPlucker() (*GoPlucker, error) {
-	_, err := exec.LookPath(GoPluckCmd)
-	if err != nil {
-		return nil, fmt.Errorf(
-			"%w: %w: install '%s' via 'go install %s'",
-			ErrGoPlucker,
-			ErrPluckCmdNotFound,
-			GoPluckCmd,
-			GoPluckCLISource,
-		)
-	}
 	return &GoPlucker{}, nil
 }
 
@@ -41,11 +50,32 @@ func (g *GoPlucker) Pluck(
 	code string,
 	name string,
 	kind Kind,
+) (string, error) {
+	return g.pluck(ctx, code, name, kind, false)
+}
+
+// PluckWithDoc plucks like Pluck, but keeps the doc comment attached to the
+// declaration in front of it.
+func (g *GoPlucker) PluckWithDoc(
+	ctx context.Context,
+	code string,
+	name string,
+	kind Kind,
+) (string, error) {
+	return g.pluck(ctx, code, name, kind, true)
+}
+
+func (g *GoPlucker) pluck(
+	ctx context.Context,
+	code string,
+	name string,
+	kind Kind,
+	withDoc bool,
 ) (string, error) {
 	switch kind {
 	case File:
 		return code, nil
-	case Func, Type:
+	case Func, Type, Const, Var, Signature:
 		break
 	case Node:
 		return "", fmt.Errorf("%w: node kind not supported", ErrGoPlucker)
@@ -53,23 +83,376 @@ func (g *GoPlucker) Pluck(
 		return "", fmt.Errorf("%w: unrecognized kind: %v", ErrGoPlucker, kind)
 	}
 
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	pick := fmt.Sprintf("%s=%s:%s", PickArg, kind, name)
-
-	cmd := exec.CommandContext(ctx, GoPluckCmd, pick)
-	cmd.Stdin = strings.NewReader(code)
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	err := ctx.Err()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrGoPlucker, err)
+	}
 
-	err := cmd.Run()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(
+		fset,
+		"",
+		code,
+		parser.ParseComments|parser.SkipObjectResolution,
+	)
 	if err != nil {
+		return "", fmt.Errorf("%w: parsing: %w", ErrGoPlucker, err)
+	}
+
+	node, prefix, ok := FindDecl(file, name, kind)
+	if !ok {
+		return "", fmt.Errorf("%w: %w: %s %s", ErrGoPlucker, ErrDeclNotFound, kind, name)
+	}
+
+	// A const spec without a type or value repeats those of the spec before
+	// it, e.g., in an iota group, so on its own it is not valid Go.
+	valueSpec, isValue := node.(*ast.ValueSpec)
+	if isValue && valueSpec.Type == nil && len(valueSpec.Values) == 0 {
 		return "", fmt.Errorf(
-			"%w: running %s: %s",
+			"%w: %w: %s %s repeats an earlier spec, pluck %s%s%s instead",
 			ErrGoPlucker,
-			GoPluckCmd,
-			stderr.String(),
+			ErrImplicitSpec,
+			kind,
+			name,
+			GroupPrefix,
+			name,
+			GroupSuffix,
 		)
 	}
-	return out.String(), nil
+
+	field, isField := node.(*ast.Field)
+	var snippet string
+	switch {
+	case kind == Signature:
+		snippet, err = SignatureSnippet(node)
+		if err != nil {
+			return "", fmt.Errorf("%w: printing signature: %w", ErrGoPlucker, err)
+		}
+	case isField:
+		return FieldSnippet(fset, code, field, withDoc), nil
+	default:
+		start := fset.Position(node.Pos()).Offset
+		end := fset.Position(node.End()).Offset
+		snippet = prefix + code[start:end] + "\n"
+	}
+
+	doc := FindDoc(file, node)
+	if !withDoc || doc == nil {
+		return snippet, nil
+	}
+	return LinesSnippet(fset, code, doc.Pos(), doc.End()) + snippet, nil
+}
+
+// FindDecl returns the node of the declaration of kind called name in file,
+// and the text the snippet of the node needs in front of it.
+func FindDecl(file *ast.File, name string, kind Kind) (ast.Node, string, bool) {
+	switch kind {
+	case Func:
+		funcDecl := FindFuncDecl(file, name)
+		if funcDecl != nil {
+			return funcDecl, "", true
+		}
+
+		// Interface methods are functions too.
+		field := FindTypeMember(file, name)
+		if field == nil {
+			return nil, "", false
+		}
+		_, isMethod := field.Type.(*ast.FuncType)
+		return field, "", isMethod
+	case Type:
+		if strings.Contains(name, NameSeparator) {
+			field := FindTypeMember(file, name)
+			return field, "", field != nil
+		}
+
+		// A spec starts at its name, even outside of a group.
+		typeSpec := FindTypeSpec(file, name)
+		return typeSpec, token.TYPE.String() + " ", typeSpec != nil
+	case Const, Var:
+		tok := token.CONST
+		if kind == Var {
+			tok = token.VAR
+		}
+
+		group, isGroup := strings.CutPrefix(name, GroupPrefix)
+		group, hasSuffix := strings.CutSuffix(group, GroupSuffix)
+		if isGroup && hasSuffix {
+			genDecl, _ := FindValueSpec(file, group, tok)
+			return genDecl, "", genDecl != nil
+		}
+
+		_, valueSpec := FindValueSpec(file, name, tok)
+		return valueSpec, tok.String() + " ", valueSpec != nil
+	case Signature:
+		node, prefix, ok := FindDecl(file, name, Func)
+		if ok {
+			return node, prefix, ok
+		}
+		return FindDecl(file, name, Type)
+	case Node, File:
+		break
+	}
+	return nil, "", false
+}
+
+// SignatureSnippet prints the signature of a function, method, interface
+// method, or type on a single line. Printing the node without the file set it
+// was parsed with drops the line breaks and comments of the source.
+func SignatureSnippet(node ast.Node) (string, error) {
+	var header ast.Node
+	prefix := ""
+	trim := ""
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		header = &ast.FuncDecl{Recv: n.Recv, Name: n.Name, Type: n.Type}
+	case *ast.Field:
+		funcType, ok := n.Type.(*ast.FuncType)
+		if !ok || len(n.Names) == 0 {
+			return "", fmt.Errorf("%w: not a method", ErrDeclNotFound)
+		}
+		// Interface methods are printed as functions, less the keyword.
+		header = &ast.FuncDecl{Name: n.Names[0], Type: funcType}
+		trim = token.FUNC.String() + " "
+	case *ast.TypeSpec:
+		typeSpec := &ast.TypeSpec{
+			Name:       n.Name,
+			TypeParams: n.TypeParams,
+			Assign:     n.Assign,
+			Type:       n.Type,
+		}
+		switch n.Type.(type) {
+		case *ast.StructType:
+			typeSpec.Type = ast.NewIdent(token.STRUCT.String())
+		case *ast.InterfaceType:
+			typeSpec.Type = ast.NewIdent(token.INTERFACE.String())
+		}
+		header = typeSpec
+		prefix = token.TYPE.String() + " "
+	default:
+		return "", fmt.Errorf("%w: %T has no signature", ErrDeclNotFound, node)
+	}
+
+	var signature bytes.Buffer
+	err := printer.Fprint(&signature, token.NewFileSet(), header)
+	if err != nil {
+		return "", err
+	}
+	return prefix + strings.TrimPrefix(signature.String(), trim) + "\n", nil
+}
+
+// FindDoc returns the doc comment of the declaration or member node, or nil.
+// The doc comment of a type or value spec that is not part of a group is
+// attached to the declaration around it.
+func FindDoc(file *ast.File, node ast.Node) *ast.CommentGroup {
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		return n.Doc
+	case *ast.GenDecl:
+		return n.Doc
+	case *ast.Field:
+		return n.Doc
+	case *ast.TypeSpec:
+		if n.Doc != nil {
+			return n.Doc
+		}
+	case *ast.ValueSpec:
+		if n.Doc != nil {
+			return n.Doc
+		}
+	default:
+		return nil
+	}
+
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if ok && !genDecl.Lparen.IsValid() && genDecl.Pos() <= node.Pos() &&
+			node.End() <= genDecl.End() {
+			return genDecl.Doc
+		}
+	}
+	return nil
+}
+
+// FindFuncDecl returns the function or method called name in file, or nil.
+func FindFuncDecl(file *ast.File, name string) *ast.FuncDecl {
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if ok && FuncName(funcDecl) == name {
+			return funcDecl
+		}
+	}
+	return nil
+}
+
+// FindTypeSpec returns the type called name in file, or nil.
+func FindTypeSpec(file *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if ok && typeSpec.Name.Name == name {
+				return typeSpec
+			}
+		}
+	}
+	return nil
+}
+
+// FindTypeMember returns the struct field or interface method at path, e.g.,
+// "Processor.cacher" or "Cacher.Retrieve", or nil.
+func FindTypeMember(file *ast.File, path string) *ast.Field {
+	names := strings.Split(path, NameSeparator)
+	typeSpec := FindTypeSpec(file, names[0])
+	if typeSpec == nil {
+		return nil
+	}
+
+	var member *ast.Field
+	expr := typeSpec.Type
+	for _, name := range names[1:] {
+		member = FindMember(Members(expr), name)
+		if member == nil {
+			return nil
+		}
+		expr = member.Type
+	}
+	return member
+}
+
+// Members returns the fields of a struct type or the methods and embedded
+// types of an interface type, or nil if expr is neither.
+func Members(expr ast.Expr) *ast.FieldList {
+	switch e := expr.(type) {
+	case *ast.StructType:
+		return e.Fields
+	case *ast.InterfaceType:
+		return e.Methods
+	case *ast.StarExpr:
+		return Members(e.X)
+	default:
+		return nil
+	}
+}
+
+// FindMember returns the member of members called name, or nil. Embedded
+// fields are called by the name of their type.
+func FindMember(members *ast.FieldList, name string) *ast.Field {
+	if members == nil {
+		return nil
+	}
+	for _, member := range members.List {
+		if len(member.Names) == 0 && BaseTypeName(member.Type) == name {
+			return member
+		}
+		for _, ident := range member.Names {
+			if ident.Name == name {
+				return member
+			}
+		}
+	}
+	return nil
+}
+
+// FieldSnippet returns the source text of field with its line comment, and
+// its doc comment if withDoc is set, less the indentation of its first line.
+func FieldSnippet(
+	fset *token.FileSet,
+	code string,
+	field *ast.Field,
+	withDoc bool,
+) string {
+	start := field.Pos()
+	if withDoc && field.Doc != nil {
+		start = field.Doc.Pos()
+	}
+	end := field.End()
+	if field.Comment != nil {
+		end = field.Comment.End()
+	}
+
+	return LinesSnippet(fset, code, start, end)
+}
+
+// LinesSnippet returns the source text from start to end, less the
+// indentation of the line that start is on.
+func LinesSnippet(fset *token.FileSet, code string, start, end token.Pos) string {
+	file := fset.File(start)
+	lineStart := file.LineStart(file.Line(start))
+	text := code[fset.Position(lineStart).Offset:fset.Position(end).Offset]
+	return Dedent(text) + "\n"
+}
+
+// Dedent removes the indentation of the first line of text from every line.
+func Dedent(text string) string {
+	indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FindValueSpec returns the const or var spec, depending on tok, that declares
+// name in file, and the declaration it is part of, or nil.
+func FindValueSpec(
+	file *ast.File,
+	name string,
+	tok token.Token,
+) (*ast.GenDecl, *ast.ValueSpec) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != tok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, ident := range valueSpec.Names {
+				if ident.Name == name {
+					return genDecl, valueSpec
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// FuncName returns the name of a function, or "Type.Method" for a method.
+func FuncName(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return funcDecl.Name.Name
+	}
+	return strings.Join(
+		[]string{BaseTypeName(funcDecl.Recv.List[0].Type), funcDecl.Name.Name},
+		NameSeparator,
+	)
+}
+
+// BaseTypeName returns the name of a type without pointers, package, or type
+// parameters, e.g., "Stack" for "*Stack[T]" or "Cacher" for "cache.Cacher".
+func BaseTypeName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			expr = e.Sel
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
 }
@@ -10,10 +10,10 @@ import (
 )
 
 const (
-	goPlucker             = "GoPlucker"
-	goPluckerSnippet      = `type GoPlucker struct{}`
-	goPluckerPluck        = "GoPlucker.Pluck"
-	goPluckerPluckSnippet = `func (g *GoPlucker) Pluck(
+	goPlucker             = "ExecGoPlucker"
+	goPluckerSnippet      = `type ExecGoPlucker struct{}`
+	goPluckerPluck        = "ExecGoPlucker.Pluck"
+	goPluckerPluckSnippet = `func (e *ExecGoPlucker) Pluck(
 	ctx context.Context,
 	code string,
 	name string,
@@ -24,10 +24,10 @@ const (
 		return code, nil
 	case Func, Type:
 		break
-	case Node:
-		return "", fmt.Errorf("%w: node kind not supported", ErrGoPlucker)
+	case Const, Var, Signature, Node:
+		return "", fmt.Errorf("%w: %s kind not supported", ErrExecGoPlucker, kind)
 	default:
-		return "", fmt.Errorf("%w: unrecognized kind: %v", ErrGoPlucker, kind)
+		return "", fmt.Errorf("%w: unrecognized kind: %v", ErrExecGoPlucker, kind)
 	}
 
 	var out bytes.Buffer
@@ -43,20 +43,100 @@ const (
 	if err != nil {
 		return "", fmt.Errorf(
 			"%w: running %s: %s",
-			ErrGoPlucker,
+			ErrExecGoPlucker,
 			GoPluckCmd,
 			stderr.String(),
 		)
 	}
 	return out.String(), nil
 }`
+	genericSource = `package stack
+
+type Stack[T any] struct {
+	items []T
+}
+
+type (
+	Pair[K comparable, V any] struct{ Entry map[K]V }
+	ID                        int
+	Set                       map[ID]struct {
+		// Count counts.
+		Count int
+	}
+)
+
+func (s *Stack[T]) Push(item T) {
+	s.items = append(s.items, item)
+}
+
+func (Pair[K, V]) String() string { return "" }
+`
+	valueSource = `package kind
+
+// Kind is a kind.
+type Kind int
+
+const (
+	// Type is a type.
+	Type Kind = iota
+	Func
+	Node
+)
+
+var ErrKind = errors.New("kind")
+
+var (
+	a, b = 1, 2
+	// long is long.
+	long string = "long" // line comment
+	n    int    = 1
+)
+`
+	memberSource = `package cache
+
+type Processor struct {
+	// cacher stores snippets.
+	cacher Cacher ` + "`yaml:\"cacher\"`" + ` // not exported
+	config struct {
+		Timeout int
+	}
+	cache.Embedded
+}
+
+// Cacher caches.
+type Cacher interface {
+	// Retrieve returns data.
+	Retrieve(ctx context.Context, uri string) ([]byte, error)
+}
+`
+	signatureSource = `package kind
+
+// Alias is an alias.
+type Alias = Kind
+
+type Handler func(
+	ctx context.Context,
+	kind Kind,
+) error
+
+type Number interface {
+	~int | ~float64
+}
+
+func (s *Stack[T]) Push(
+	item T, // pushed last
+	more ...T,
+) (int, error) {
+	return 0, nil
+}
+`
 )
 
-//go:embed goplucker.go
+//go:embed execgoplucker.go
 var goPluckerSource string
 
 func TestGoPlucker_Pluck(t *testing.T) {
-	t.Run("happy path - GoPlucker (type)", func(t *testing.T) {
+	t.Run("happy path - ExecGoPlucker (type)", func(t *testing.T) {
 		// given
 		ctx := context.Background()
 		code := goPluckerSource
@@ -74,7 +154,7 @@ func TestGoPlucker_Pluck(t *testing.T) {
 		require.Equal(t, want, got)
 	})
 
-	t.Run("happy path - GoPlucker.Pluck (func)", func(t *testing.T) {
+	t.Run("happy path - ExecGoPlucker.Pluck (func)", func(t *testing.T) {
 		// given
 		ctx := context.Background()
 		code := goPluckerSource
@@ -140,4 +220,264 @@ func TestGoPlucker_Pluck(t *testing.T) {
 		// then
 		require.Error(t, err)
 	})
+
+	// Like pluck, specs from a group are copied verbatim, with the alignment
+	// padding and indentation they have inside the group.
+	tests := []struct {
+		name string
+		kind pluck.Kind
+		want string
+	}{
+		{name: "Stack", kind: pluck.Type, want: "type Stack[T any] struct {\n\titems []T\n}\n"},
+		{name: "Pair", kind: pluck.Type, want: "type Pair[K comparable, V any] struct{ Entry map[K]V }\n"},
+		{name: "ID", kind: pluck.Type, want: "type ID                        int\n"},
+		{
+			name: "Set",
+			kind: pluck.Type,
+			want: "type Set                       map[ID]struct {\n\t\t// Count counts.\n\t\tCount int\n\t}\n",
+		},
+		{name: "Stack.Push", kind: pluck.Func, want: "func (s *Stack[T]) Push(item T) {\n\ts.items = append(s.items, item)\n}\n"},
+		{name: "Pair.String", kind: pluck.Func, want: "func (Pair[K, V]) String() string { return \"\" }\n"},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - generic "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+
+			// when
+			got, err := plucker.Pluck(ctx, genericSource, tt.name, tt.kind)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	valueTests := []struct {
+		name string
+		kind pluck.Kind
+		want string
+	}{
+		{name: "(Func)", kind: pluck.Const, want: "const (\n\t// Type is a type.\n\tType Kind = iota\n\tFunc\n\tNode\n)\n"},
+		{name: "Type", kind: pluck.Const, want: "const Type Kind = iota\n"},
+		{name: "ErrKind", kind: pluck.Var, want: "var ErrKind = errors.New(\"kind\")\n"},
+		{name: "(ErrKind)", kind: pluck.Var, want: "var ErrKind = errors.New(\"kind\")\n"},
+		{name: "b", kind: pluck.Var, want: "var a, b = 1, 2\n"},
+		{name: "long", kind: pluck.Var, want: "var long string = \"long\"\n"},
+		{name: "n", kind: pluck.Var, want: "var n    int    = 1\n"},
+	}
+	for _, tt := range valueTests {
+		t.Run("happy path - "+string(tt.kind)+" "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+
+			// when
+			got, err := plucker.Pluck(ctx, valueSource, tt.name, tt.kind)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("error - implicit iota spec", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, valueSource, "Func", pluck.Const)
+
+		// then
+		require.ErrorIs(t, err, pluck.ErrImplicitSpec)
+		require.ErrorContains(t, err, "(Func)")
+	})
+
+	t.Run("error - const is not a var", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, valueSource, "Type", pluck.Var)
+
+		// then
+		require.ErrorIs(t, err, pluck.ErrDeclNotFound)
+	})
+
+	memberTests := []struct {
+		name string
+		kind pluck.Kind
+		want string
+	}{
+		{
+			name: "Processor.cacher",
+			kind: pluck.Type,
+			want: "cacher Cacher `yaml:\"cacher\"` // not exported\n",
+		},
+		{name: "Processor.config", kind: pluck.Type, want: "config struct {\n\tTimeout int\n}\n"},
+		{name: "Processor.config.Timeout", kind: pluck.Type, want: "Timeout int\n"},
+		{name: "Processor.Embedded", kind: pluck.Type, want: "cache.Embedded\n"},
+		{
+			name: "Cacher.Retrieve",
+			kind: pluck.Func,
+			want: "Retrieve(ctx context.Context, uri string) ([]byte, error)\n",
+		},
+	}
+	for _, tt := range memberTests {
+		t.Run("happy path - member "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+
+			// when
+			got, err := plucker.Pluck(ctx, memberSource, tt.name, tt.kind)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("error - field is not a function", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, memberSource, "Processor.cacher", pluck.Func)
+
+		// then
+		require.ErrorIs(t, err, pluck.ErrDeclNotFound)
+	})
+}
+
+func TestGoPlucker_PluckWithDoc(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   pluck.Kind
+		source string
+		want   string
+	}{
+		{name: "Kind", kind: pluck.Type, source: valueSource, want: "// Kind is a kind.\ntype Kind int\n"},
+		{name: "Type", kind: pluck.Const, source: valueSource, want: "// Type is a type.\nconst Type Kind = iota\n"},
+		{
+			name:   "Cacher",
+			kind:   pluck.Type,
+			source: memberSource,
+			want:   "// Cacher caches.\ntype Cacher interface {\n\t// Retrieve returns data.\n\tRetrieve(ctx context.Context, uri string) ([]byte, error)\n}\n",
+		},
+		{
+			name:   "Cacher.Retrieve",
+			kind:   pluck.Func,
+			source: memberSource,
+			want:   "// Retrieve returns data.\nRetrieve(ctx context.Context, uri string) ([]byte, error)\n",
+		},
+		{
+			name:   "Processor.cacher",
+			kind:   pluck.Type,
+			source: memberSource,
+			want:   "// cacher stores snippets.\ncacher Cacher `yaml:\"cacher\"` // not exported\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+string(tt.kind)+" "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+
+			// when
+			got, err := plucker.PluckWithDoc(ctx, tt.source, tt.name, tt.kind)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGoPlucker_Pluck_Signature(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "Stack.Push", source: signatureSource, want: "func (s *Stack[T]) Push(item T, more ...T) (int, error)\n"},
+		{name: "Alias", source: signatureSource, want: "type Alias = Kind\n"},
+		{name: "Handler", source: signatureSource, want: "type Handler func(ctx context.Context, kind Kind) error\n"},
+		{name: "Number", source: signatureSource, want: "type Number interface\n"},
+		{name: "Kind", source: valueSource, want: "type Kind int\n"},
+		{name: "Stack", source: genericSource, want: "type Stack[T any] struct\n"},
+		{name: "Pair.String", source: genericSource, want: "func (Pair[K, V]) String() string\n"},
+		{
+			name:   "Cacher.Retrieve",
+			source: memberSource,
+			want:   "Retrieve(ctx context.Context, uri string) ([]byte, error)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// given
+			ctx := context.Background()
+			plucker, err := pluck.NewGoPlucker()
+			require.NoError(t, err)
+
+			// when
+			got, err := plucker.Pluck(ctx, tt.source, tt.name, pluck.Signature)
+
+			// then
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("happy path - with doc", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		got, err := plucker.PluckWithDoc(ctx, signatureSource, "Alias", pluck.Signature)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, "// Alias is an alias.\ntype Alias = Kind\n", got)
+	})
+
+	t.Run("happy path - interface method with doc", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		got, err := plucker.PluckWithDoc(ctx, memberSource, "Cacher.Retrieve", pluck.Signature)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, "// Retrieve returns data.\nRetrieve(ctx context.Context, uri string) ([]byte, error)\n", got)
+	})
+
+	t.Run("error - field has no signature", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		plucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		// when
+		_, err = plucker.Pluck(ctx, memberSource, "Processor.cacher", pluck.Signature)
+
+		// then
+		require.ErrorIs(t, err, pluck.ErrDeclNotFound)
+	})
 }
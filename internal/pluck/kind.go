@@ -3,15 +3,18 @@ package pluck
 type Kind string
 
 const (
-	Type Kind = "type"
-	Func Kind = "function"
-	Node Kind = "node"
-	File Kind = "file"
+	Type      Kind = "type"
+	Func      Kind = "function"
+	Const     Kind = "const"
+	Var       Kind = "var"
+	Signature Kind = "signature"
+	Node      Kind = "node"
+	File      Kind = "file"
 )
 
 func (k Kind) Valid() bool {
 	switch k {
-	case Type, Func, Node, File:
+	case Type, Func, Const, Var, Signature, Node, File:
 		return true
 	default:
 		return false
@@ -1,5 +1,10 @@
 package pluck
 
+import (
+	"path"
+	"strings"
+)
+
 type Lang string
 
 const (
@@ -15,3 +20,34 @@ func (l Lang) Valid() bool {
 		return false
 	}
 }
+
+// Supports reports whether the plucker for l can pluck snippets of kind k.
+func (l Lang) Supports(k Kind) bool {
+	switch l {
+	case Go:
+		return k == Type || k == Func || k == Const || k == Var || k == Signature ||
+			k == File
+	case YAML:
+		return k == Node || k == File
+	default:
+		return false
+	}
+}
+
+// SupportsDoc reports whether the plucker for l can pluck snippets of kind k
+// together with their doc comments.
+func (l Lang) SupportsDoc(k Kind) bool {
+	return l == Go && (k == Type || k == Func || k == Const || k == Var || k == Signature)
+}
+
+// LangFromPath infers the lang of a source file from its extension.
+func LangFromPath(source string) (Lang, bool) {
+	switch strings.ToLower(path.Ext(source)) {
+	case ".go":
+		return Go, true
+	case ".yaml", ".yml":
+		return YAML, true
+	default:
+		return "", false
+	}
+}
@@ -5,3 +5,10 @@ import "context"
 type Plucker interface {
 	Pluck(ctx context.Context, code string, name string, kind Kind) (snippet string, err error)
 }
+
+// DocPlucker is a Plucker that can also pluck declarations together with the
+// doc comments attached to them.
+type DocPlucker interface {
+	Plucker
+	PluckWithDoc(ctx context.Context, code string, name string, kind Kind) (snippet string, err error)
+}
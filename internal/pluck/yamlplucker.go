@@ -35,8 +35,8 @@ func (y *YAMLPlucker) Pluck(
 		return code, nil
 	case Node:
 		break
-	case Func, Type:
-		return "", fmt.Errorf("%w: func and type kind not supported", ErrYAMLPlucker)
+	case Func, Type, Const, Var, Signature:
+		return "", fmt.Errorf("%w: %s kind not supported", ErrYAMLPlucker, kind)
 	default:
 		return "", fmt.Errorf("%w: unrecognized kind: %v", ErrYAMLPlucker, kind)
 	}
@@ -0,0 +1,109 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DiagnosticError describes a problem with a pluck directive. It records where
+// the directive is (File, Line, and Column are 1-based and may be unset when
+// not known), the directive text, and the snippet URI the directive refers to.
+type DiagnosticError struct {
+	File      string
+	Line      int
+	Column    int
+	Directive string
+	URI       string
+	Err       error
+}
+
+func NewDiagnosticError(
+	file string,
+	line int,
+	column int,
+	directive string,
+	uri string,
+	err error,
+) *DiagnosticError {
+	return &DiagnosticError{
+		File:      file,
+		Line:      line,
+		Column:    column,
+		Directive: strings.TrimSpace(directive),
+		URI:       uri,
+		Err:       err,
+	}
+}
+
+// Error formats the diagnostic compiler-style as "file:line:col: message",
+// leaving out any position fields that are not set.
+func (d *DiagnosticError) Error() string {
+	var pos strings.Builder
+	if d.File != "" {
+		pos.WriteString(d.File + ":")
+	}
+	if d.Line > 0 {
+		fmt.Fprintf(&pos, "%d:", d.Line)
+		if d.Column > 0 {
+			fmt.Fprintf(&pos, "%d:", d.Column)
+		}
+	}
+
+	if pos.Len() == 0 {
+		return d.Err.Error()
+	}
+	return pos.String() + " " + d.Err.Error()
+}
+
+func (d *DiagnosticError) Unwrap() error {
+	return d.Err
+}
+
+// DiagnosticErrors is a list of diagnostics reported together, e.g., every
+// failing directive in a markdown file.
+type DiagnosticErrors []*DiagnosticError
+
+// Error formats each diagnostic on its own line.
+func (d DiagnosticErrors) Error() string {
+	lines := make([]string, 0, len(d))
+	for _, diagnostic := range d {
+		lines = append(lines, diagnostic.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d DiagnosticErrors) Unwrap() []error {
+	errs := make([]error, 0, len(d))
+	for _, diagnostic := range d {
+		errs = append(errs, diagnostic)
+	}
+	return errs
+}
+
+// ToDiagnosticErrors converts err into diagnostics. Errors that are not already
+// diagnostics become a single diagnostic for file with no position.
+func ToDiagnosticErrors(file string, err error) DiagnosticErrors {
+	var diagnostics DiagnosticErrors
+	if errors.As(err, &diagnostics) {
+		return diagnostics
+	}
+
+	var diagnostic *DiagnosticError
+	if errors.As(err, &diagnostic) {
+		return DiagnosticErrors{diagnostic}
+	}
+	return DiagnosticErrors{NewDiagnosticError(file, 0, 0, "", "", err)}
+}
+
+// DirectiveColumn returns the 1-based column at which the pluck comment in
+// line starts, or 1 if the line does not contain a comment.
+func DirectiveColumn(line string) int {
+	if loc := PluckRegex.FindStringIndex(line); loc != nil {
+		return loc[0] + 1
+	}
+	if i := strings.Index(line, commentStart); i != -1 {
+		return i + 1
+	}
+	return 1
+}
@@ -1,12 +1,17 @@
 package process
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/tahardi/pluckmd/internal/pluck"
+	"github.com/tahardi/pluckmd/internal/snip"
 )
 
 const (
@@ -17,10 +22,28 @@ const (
 	StartIndex  = 5
 	EndIndex    = 6
 	NumFields   = 7
+
+	// LangArg, KindArg, NameArg, SourceArg, LinesArg, StatementsArg,
+	// MarkersArg, and DocArg are the names of keyword directive arguments.
+	LangArg       = "lang"
+	KindArg       = "kind"
+	NameArg       = "name"
+	SourceArg     = "source"
+	LinesArg      = "lines"
+	StatementsArg = "statements"
+	MarkersArg    = "markers"
+	DocArg        = "doc"
+
+	LinesSeparator = ":"
+	ListSeparator  = ","
+	DocURISuffix   = ".doc"
 )
 
 var (
-	ErrDirective = errors.New("pluck")
+	ErrDirective       = errors.New("pluck")
+	ErrUnknownArgument = errors.New("unknown argument")
+	ErrInvalidLines    = errors.New("invalid lines")
+	ErrInvalidMarkers  = errors.New("invalid markers")
 
 	// Regex subcomponents for building PluckRegex
 	commentStart = `<!--`
@@ -40,7 +63,12 @@ var (
 	//	source = relative path for local file or remote git URL
 	//  start = integer representing starting line of code body
 	//  end = integer representing ending line of code body
-	// This regex will match with the directive defined above
+	// This regex will match with the directive defined above.
+	//
+	// Directives can also be written with keyword arguments in any order:
+	// <!-- pluck(kind="function", name="Name", source="main.go", lines="0:10") -->
+	// These are parsed by ParseCall rather than a regex. See
+	// parseKeywordDirective for the defaults of omitted arguments.
 	PluckRegex = regexp.MustCompile(
 		commentStart + optionalWs + pluckName + `\(` +
 			optionalWs + quotedString + optionalWs + comma +
@@ -51,55 +79,245 @@ var (
 			optionalWs + number + optionalWs +
 			`\)` + optionalWs + commentEnd,
 	)
+
+	// EndMarkerRegex matches the optional "<!-- /pluck -->" comment that ends
+	// the region managed by the directive before it. See FindRegion.
+	EndMarkerRegex = regexp.MustCompile(
+		`^` + optionalWs + commentStart + optionalWs + `/` + pluckName +
+			optionalWs + commentEnd + optionalWs + `$`,
+	)
 )
 
 func ContainsPluckDirective(line string) bool {
 	fields := PluckRegex.FindStringSubmatch(line)
-	return len(fields) == NumFields
+	return len(fields) == NumFields || ContainsKeywordDirective(line)
+}
+
+// DirectiveComment is a comment in markdown that looks like a pluck directive.
+// The comment may span several lines, e.g., with one argument per line, in
+// which case Text holds lines First through Last joined by newlines.
+type DirectiveComment struct {
+	First int
+	Last  int
+	Text  string
+}
+
+// FindDirectiveComment returns the comment starting on lines[i] if it looks
+// like a pluck directive. The comment may also open with "<!--" on a line of
+// its own, followed by "pluck(" on the next line. If the comment is not closed
+// on lines[i], it extends to the first following line containing "-->". Use
+// ContainsPluckDirective on the comment's Text to check whether it really is
+// a directive.
+func FindDirectiveComment(lines []string, i int) (*DirectiveComment, bool) {
+	line := lines[i]
+	var open int
+	if loc := NearMissRegex.FindStringIndex(line); loc != nil {
+		open = loc[0]
+	} else {
+		open = strings.Index(line, commentStart)
+		if open == -1 ||
+			strings.TrimSpace(line[open+len(commentStart):]) != "" ||
+			i+1 >= len(lines) ||
+			!strings.HasPrefix(strings.TrimSpace(lines[i+1]), pluckName) {
+			return nil, false
+		}
+	}
+
+	comment := &DirectiveComment{First: i, Last: i, Text: line}
+	if strings.Contains(line[open+len(commentStart):], commentEnd) {
+		return comment, true
+	}
+
+	for last := i + 1; last < len(lines); last++ {
+		if strings.Contains(lines[last], commentEnd) {
+			comment.Last = last
+			comment.Text = strings.Join(lines[i:last+1], "\n")
+			break
+		}
+	}
+	return comment, true
+}
+
+// ContainsKeywordDirective reports whether line contains a syntactically valid
+// directive whose first argument is a keyword argument.
+func ContainsKeywordDirective(line string) bool {
+	args, err := parseKeywordCall(line)
+	return err == nil && len(args) > 0 && args[0].Name != ""
 }
 
 type Directive struct {
-	lang   pluck.Lang
-	kind   pluck.Kind
-	name   string
-	source string
-	start  int
-	end    int
+	lang      pluck.Lang
+	kind      pluck.Kind
+	name      string
+	source    string
+	start     int
+	end       int
+	doc       bool
+	selection snip.Selection
 }
 
 func NewDirective(line string) (*Directive, error) {
+	directive, err := parseDirective(line)
+	if err != nil {
+		return nil, NewDiagnosticError("", 0, DirectiveColumn(line), line, "", err)
+	}
+	return directive, nil
+}
+
+func parseDirective(line string) (*Directive, error) {
 	fields := PluckRegex.FindStringSubmatch(line)
 	if len(fields) != NumFields {
-		return nil, fmt.Errorf("%w: directive incorrect num fields: %s", ErrDirective, line)
+		if ContainsKeywordDirective(line) {
+			return parseKeywordDirective(line)
+		}
+		return nil, fmt.Errorf("%w: directive incorrect num fields", ErrDirective)
 	}
 
 	start, err := strconv.Atoi(fields[StartIndex])
 	if err != nil {
-		return nil, fmt.Errorf("%w: invalid start index: %s", ErrDirective, line)
+		return nil, fmt.Errorf("%w: invalid start index: %s", ErrDirective, fields[StartIndex])
 	}
 	end, err := strconv.Atoi(fields[EndIndex])
 	if err != nil {
-		return nil, fmt.Errorf("%w: invalid end index: %s", ErrDirective, line)
+		return nil, fmt.Errorf("%w: invalid end index: %s", ErrDirective, fields[EndIndex])
 	}
 
-	lang := pluck.Lang(fields[LangIndex])
-	if !lang.Valid() {
-		return nil, fmt.Errorf("%w: invalid lang: %s", ErrDirective, line)
-	}
-
-	kind := pluck.Kind(fields[KindIndex])
-	if !kind.Valid() {
-		return nil, fmt.Errorf("%w: invalid kind: %s", ErrDirective, line)
-	}
-
-	return &Directive{
-		lang:   lang,
-		kind:   kind,
+	directive := &Directive{
+		lang:   pluck.Lang(fields[LangIndex]),
+		kind:   pluck.Kind(fields[KindIndex]),
 		name:   fields[NameIndex],
 		source: fields[SourceIndex],
 		start:  start,
 		end:    end,
-	}, nil
+	}
+	return directive, directive.validate()
+}
+
+// parseKeywordDirective parses a directive written with keyword arguments.
+// Only source is required. If omitted, lang is inferred from the extension of
+// source, kind defaults to file if there is no name and to node for YAML,
+// name defaults to the base name of source for file kinds, lines defaults to
+// "0:0" (the full snippet), and doc defaults to false.
+//
+// Lines may also be a comma-separated list of ranges, e.g., "0:3,10:12", and
+// can be combined with statements, a list of ranges of top-level statements,
+// and markers, a comma-separated list of marker comment names. See
+// snip.Selection.
+func parseKeywordDirective(line string) (*Directive, error) {
+	args, err := parseKeywordCall(line)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDirective, err)
+	}
+
+	directive := &Directive{start: snip.FullStart, end: snip.FullEnd}
+	seen := make(map[string]bool, len(args))
+	for _, arg := range args {
+		if arg.Name == "" {
+			return nil, fmt.Errorf(
+				"%w: positional argument %s mixed with keyword arguments",
+				ErrDirective,
+				arg.Value.Text,
+			)
+		}
+		if seen[arg.Name] {
+			return nil, fmt.Errorf("%w: duplicate argument: %s", ErrDirective, arg.Name)
+		}
+		seen[arg.Name] = true
+
+		err = directive.setArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDirective, err)
+		}
+	}
+
+	if directive.source == "" {
+		return nil, fmt.Errorf("%w: missing argument: %s", ErrDirective, SourceArg)
+	}
+
+	if directive.lang == "" {
+		lang, ok := pluck.LangFromPath(directive.source)
+		if !ok {
+			return nil, fmt.Errorf(
+				"%w: cannot infer lang from source: %s",
+				ErrDirective,
+				directive.source,
+			)
+		}
+		directive.lang = lang
+	}
+
+	if directive.kind == "" {
+		switch {
+		case directive.name == "":
+			directive.kind = pluck.File
+		case directive.lang == pluck.YAML:
+			directive.kind = pluck.Node
+		default:
+			return nil, fmt.Errorf("%w: missing argument: %s", ErrDirective, KindArg)
+		}
+	}
+
+	if directive.name == "" {
+		if directive.kind != pluck.File {
+			return nil, fmt.Errorf("%w: missing argument: %s", ErrDirective, NameArg)
+		}
+		directive.name = path.Base(directive.source)
+	}
+	return directive, directive.validate()
+}
+
+func parseKeywordCall(line string) ([]Arg, error) {
+	loc := NearMissRegex.FindStringIndex(line)
+	if loc == nil {
+		return nil, fmt.Errorf("%w: directive not found", ErrSyntax)
+	}
+	args, _, err := ParseCall(line[loc[0]:])
+	return args, err
+}
+
+// ParseRanges parses a comma-separated list of ranges of the form
+// "start:end".
+func ParseRanges(ranges string) ([]snip.Range, error) {
+	var parsed []snip.Range
+	for r := range strings.SplitSeq(ranges, ListSeparator) {
+		start, end, err := ParseLines(r)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, snip.Range{Start: start, End: end})
+	}
+	return parsed, nil
+}
+
+// ParseMarkers parses a comma-separated list of marker names.
+func ParseMarkers(markers string) ([]string, error) {
+	var parsed []string
+	for marker := range strings.SplitSeq(markers, ListSeparator) {
+		marker = strings.TrimSpace(marker)
+		if marker == "" || strings.ContainsFunc(marker, unicode.IsSpace) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMarkers, markers)
+		}
+		parsed = append(parsed, marker)
+	}
+	return parsed, nil
+}
+
+// ParseLines parses a range of the form "start:end".
+func ParseLines(lines string) (int, int, error) {
+	startStr, endStr, ok := strings.Cut(lines, LinesSeparator)
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %q: expected start:end", ErrInvalidLines, lines)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q: invalid start", ErrInvalidLines, lines)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q: invalid end", ErrInvalidLines, lines)
+	}
+	return start, end, nil
 }
 
 func (d *Directive) Lang() pluck.Lang {
@@ -114,6 +332,10 @@ func (d *Directive) Name() string {
 	return d.name
 }
 
+func (d *Directive) Source() string {
+	return d.source
+}
+
 func (d *Directive) Start() int {
 	return d.start
 }
@@ -122,10 +344,113 @@ func (d *Directive) End() int {
 	return d.end
 }
 
+// Doc reports whether the snippet should include the doc comment of the
+// declaration.
+func (d *Directive) Doc() bool {
+	return d.doc
+}
+
+// Selection returns the parts of the snippet body to show, and whether the
+// directive selects more than the single range Start to End, i.e., has
+// statements, markers, or several line ranges.
+func (d *Directive) Selection() (snip.Selection, bool) {
+	selected := len(d.selection.Statements) > 0 ||
+		len(d.selection.Markers) > 0 ||
+		len(d.selection.Lines) > 1
+	return d.selection, selected
+}
+
 func (d *Directive) CodeSnippetURI() string {
-	return d.source + "." + string(d.kind) + "." + d.name
+	uri := d.source + "." + string(d.kind) + "." + d.name
+	if d.doc {
+		uri += DocURISuffix
+	}
+	return uri
 }
 
 func (d *Directive) SourceCodeURI() string {
 	return d.source
 }
+
+// MarshalJSON encodes the parsed fields of the directive, e.g., for run
+// reports.
+func (d *Directive) MarshalJSON() ([]byte, error) {
+	// A single line range is already given by start and end.
+	var lines []snip.Range
+	if len(d.selection.Lines) > 1 {
+		lines = d.selection.Lines
+	}
+	return json.Marshal(struct {
+		Lang       pluck.Lang   `json:"lang"`
+		Kind       pluck.Kind   `json:"kind"`
+		Name       string       `json:"name"`
+		Source     string       `json:"source"`
+		Start      int          `json:"start"`
+		End        int          `json:"end"`
+		Doc        bool         `json:"doc"`
+		Lines      []snip.Range `json:"lines,omitempty"`
+		Statements []snip.Range `json:"statements,omitempty"`
+		Markers    []string     `json:"markers,omitempty"`
+	}{
+		Lang:       d.lang,
+		Kind:       d.kind,
+		Name:       d.name,
+		Source:     d.source,
+		Start:      d.start,
+		End:        d.end,
+		Doc:        d.doc,
+		Lines:      lines,
+		Statements: d.selection.Statements,
+		Markers:    d.selection.Markers,
+	})
+}
+
+func (d *Directive) setArg(arg Arg) error {
+	switch arg.Name {
+	case LangArg, KindArg, NameArg, SourceArg, LinesArg, StatementsArg, MarkersArg:
+		break
+	case DocArg:
+		var err error
+		d.doc, err = arg.Bool()
+		return err
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownArgument, arg.Name)
+	}
+
+	// Every other argument is a string.
+	value, err := arg.String()
+	if err != nil {
+		return err
+	}
+
+	switch arg.Name {
+	case LangArg:
+		d.lang = pluck.Lang(value)
+	case KindArg:
+		d.kind = pluck.Kind(value)
+	case NameArg:
+		d.name = value
+	case SourceArg:
+		d.source = value
+	case LinesArg:
+		d.selection.Lines, err = ParseRanges(value)
+		if err == nil {
+			d.start, d.end = d.selection.Lines[0].Start, d.selection.Lines[0].End
+		}
+	case StatementsArg:
+		d.selection.Statements, err = ParseRanges(value)
+	case MarkersArg:
+		d.selection.Markers, err = ParseMarkers(value)
+	}
+	return err
+}
+
+func (d *Directive) validate() error {
+	if !d.lang.Valid() {
+		return fmt.Errorf("%w: invalid lang: %s", ErrDirective, d.lang)
+	}
+	if !d.kind.Valid() {
+		return fmt.Errorf("%w: invalid kind: %s", ErrDirective, d.kind)
+	}
+	return nil
+}
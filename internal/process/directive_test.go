@@ -4,7 +4,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/pluck"
 	"github.com/tahardi/pluckmd/internal/process"
+	"github.com/tahardi/pluckmd/internal/snip"
 )
 
 func TestNewDirective(t *testing.T) {
@@ -48,6 +51,81 @@ func TestNewDirective(t *testing.T) {
 			line:    `<!-- pluck("go", "not-a-kind", "Name", "path", 0, 0) -->`,
 			wantErr: true,
 		},
+		{
+			name:    "valid - keyword arguments",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", lines="0:10") -->`,
+			wantErr: false,
+		},
+		{
+			name:    "valid - keyword arguments in any order",
+			line:    `<!--pluck( lines = "-1:-1", source="tee/verifier.go",name="Verify" , kind="type", )-->`,
+			wantErr: false,
+		},
+		{
+			name:    "invalid - keyword missing source",
+			line:    `<!-- pluck(kind="type", name="Verifier") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword unknown argument",
+			line:    `<!-- pluck(source="tee/verifier.go", start=0) -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword duplicate argument",
+			line:    `<!-- pluck(source="tee/verifier.go", source="tee/verifier.go") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword mixed with positional",
+			line:    `<!-- pluck(source="tee/verifier.go", "type") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword lang not inferred",
+			line:    `<!-- pluck(source="tee/verifier.txt") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword go without kind",
+			line:    `<!-- pluck(name="Verify", source="tee/verifier.go") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword malformed lines",
+			line:    `<!-- pluck(source="tee/verifier.go", lines="0-10") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "valid - keyword doc",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", doc=true) -->`,
+			wantErr: false,
+		},
+		{
+			name:    "valid - keyword statements, markers, and several lines",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", lines="0:2,5:6", statements="1:3", markers="exec,cache") -->`,
+			wantErr: false,
+		},
+		{
+			name:    "invalid - keyword empty marker",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", markers="exec,") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword malformed statements",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", statements="1") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword doc is not a bool",
+			line:    `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", doc="true") -->`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid - keyword lines is not a string",
+			line:    `<!-- pluck(source="tee/verifier.go", lines=10) -->`,
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -61,3 +139,163 @@ func TestNewDirective(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDirective_Diagnostic(t *testing.T) {
+	// given
+	line := `  <!-- pluck("go", "not-a-kind", "Name", "path", 0, 0) -->`
+
+	// when
+	_, err := process.NewDirective(line)
+
+	// then
+	var diagnostic *process.DiagnosticError
+	require.ErrorAs(t, err, &diagnostic)
+	require.ErrorIs(t, err, process.ErrDirective)
+	assert.Equal(t, 3, diagnostic.Column)
+	assert.Equal(t, `<!-- pluck("go", "not-a-kind", "Name", "path", 0, 0) -->`, diagnostic.Directive)
+	assert.Equal(t, "pluck: invalid kind: not-a-kind", diagnostic.Error())
+}
+
+func TestNewDirective_KeywordDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantLang  pluck.Lang
+		wantKind  pluck.Kind
+		wantName  string
+		wantStart int
+		wantEnd   int
+	}{
+		{
+			name:      "all arguments",
+			line:      `<!-- pluck(lang="go", kind="function", name="Verify", source="tee/verifier.go", lines="3:10") -->`,
+			wantLang:  pluck.Go,
+			wantKind:  pluck.Func,
+			wantName:  "Verify",
+			wantStart: 3,
+			wantEnd:   10,
+		},
+		{
+			name:     "go file",
+			line:     `<!-- pluck(source="tee/verifier.go") -->`,
+			wantLang: pluck.Go,
+			wantKind: pluck.File,
+			wantName: "verifier.go",
+		},
+		{
+			name:     "yaml node",
+			line:     `<!-- pluck(name="nonclave.measurement", source="./testdata/nonclave-sev.yml") -->`,
+			wantLang: pluck.YAML,
+			wantKind: pluck.Node,
+			wantName: "nonclave.measurement",
+		},
+		{
+			name:      "empty lines",
+			line:      `<!-- pluck(kind="type", name="Verifier", source="https://github.com/org/repo/blob/main/verifier.go", lines="-1:-1") -->`,
+			wantLang:  pluck.Go,
+			wantKind:  pluck.Type,
+			wantName:  "Verifier",
+			wantStart: -1,
+			wantEnd:   -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := process.NewDirective(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLang, got.Lang())
+			assert.Equal(t, tt.wantKind, got.Kind())
+			assert.Equal(t, tt.wantName, got.Name())
+			assert.Equal(t, tt.wantStart, got.Start())
+			assert.Equal(t, tt.wantEnd, got.End())
+		})
+	}
+}
+
+func TestFindDirectiveComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		wantOK   bool
+		wantLast int
+	}{
+		{
+			name:     "single line",
+			lines:    []string{`<!-- pluck(source="a.go") -->`, "```go"},
+			wantOK:   true,
+			wantLast: 0,
+		},
+		{
+			name:     "one argument per line",
+			lines:    []string{"<!-- pluck(", `  source="a.go",`, `  kind="file",`, ") -->", "```go"},
+			wantOK:   true,
+			wantLast: 3,
+		},
+		{
+			name:     "comment opens on its own line",
+			lines:    []string{"  <!--", "  pluck(", `    source="a.go",`, "  )", "  -->"},
+			wantOK:   true,
+			wantLast: 4,
+		},
+		{
+			name:   "plain comment",
+			lines:  []string{"<!--", "  not a directive", "-->"},
+			wantOK: false,
+		},
+		{
+			name:   "plain text",
+			lines:  []string{"plucking (things)"},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := process.FindDirectiveComment(tt.lines, 0)
+			require.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, 0, got.First)
+			assert.Equal(t, tt.wantLast, got.Last)
+			assert.True(t, process.ContainsPluckDirective(got.Text))
+		})
+	}
+}
+
+func TestDirective_Selection(t *testing.T) {
+	t.Run("happy path - single range", func(t *testing.T) {
+		// given
+		line := `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", lines="3:10") -->`
+
+		// when
+		directive, err := process.NewDirective(line)
+
+		// then
+		require.NoError(t, err)
+		_, selected := directive.Selection()
+		assert.False(t, selected)
+		assert.Equal(t, 3, directive.Start())
+		assert.Equal(t, 10, directive.End())
+	})
+
+	t.Run("happy path - statements, markers, and several lines", func(t *testing.T) {
+		// given
+		line := `<!-- pluck(kind="function", name="Verify", source="tee/verifier.go", lines="0:2, 5:6", statements="1:3", markers="exec, cache") -->`
+		want := snip.Selection{
+			Lines:      []snip.Range{{Start: 0, End: 2}, {Start: 5, End: 6}},
+			Statements: []snip.Range{{Start: 1, End: 3}},
+			Markers:    []string{"exec", "cache"},
+		}
+
+		// when
+		directive, err := process.NewDirective(line)
+
+		// then
+		require.NoError(t, err)
+		got, selected := directive.Selection()
+		assert.True(t, selected)
+		assert.Equal(t, want, got)
+	})
+}
@@ -0,0 +1,67 @@
+package process
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	LF   = "\n"
+	CRLF = "\r\n"
+)
+
+// BOM is the UTF-8 byte order mark that some editors put at the start of
+// a file.
+var BOM = []byte("\xef\xbb\xbf")
+
+// Format is the layout of a markdown file that processing preserves: whether
+// it starts with a BOM, its line ending, and whether its last line ends with
+// a line ending.
+type Format struct {
+	BOM          bool
+	LineEnding   string
+	FinalNewline bool
+}
+
+// NormalizeMarkdown returns the contents of md without a BOM and with LF line
+// endings, together with the Format needed to restore them. The line ending
+// of the first line is taken to be the line ending of the whole file.
+func NormalizeMarkdown(md []byte) (string, Format) {
+	format := Format{LineEnding: LF}
+
+	if bytes.HasPrefix(md, BOM) {
+		format.BOM = true
+		md = md[len(BOM):]
+	}
+
+	if i := bytes.IndexByte(md, '\n'); i > 0 && md[i-1] == '\r' {
+		format.LineEnding = CRLF
+	}
+
+	normalized := string(bytes.ReplaceAll(md, []byte(CRLF), []byte(LF)))
+	format.FinalNewline = len(normalized) > 0 && normalized[len(normalized)-1] == '\n'
+	return normalized, format
+}
+
+// SplitLines normalizes md with NormalizeMarkdown and splits it into lines.
+// A final line ending does not produce an empty last line.
+func SplitLines(md []byte) ([]string, Format) {
+	normalized, format := NormalizeMarkdown(md)
+	return strings.Split(strings.TrimSuffix(normalized, LF), LF), format
+}
+
+// Apply restores format to processed, markdown in which every line ends with
+// LF.
+func (f Format) Apply(processed []byte) []byte {
+	processed = bytes.ReplaceAll(processed, []byte(CRLF), []byte(LF))
+	if !f.FinalNewline {
+		processed = bytes.TrimSuffix(processed, []byte(LF))
+	}
+	if f.LineEnding == CRLF {
+		processed = bytes.ReplaceAll(processed, []byte(LF), []byte(CRLF))
+	}
+	if f.BOM {
+		processed = append(bytes.Clone(BOM), processed...)
+	}
+	return processed
+}
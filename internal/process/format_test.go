@@ -0,0 +1,34 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want process.Format
+	}{
+		{name: "lf", md: "a\nb\n", want: process.Format{LineEnding: process.LF, FinalNewline: true}},
+		{name: "crlf", md: "a\r\nb\r\n", want: process.Format{LineEnding: process.CRLF, FinalNewline: true}},
+		{name: "no final newline", md: "a\nb", want: process.Format{LineEnding: process.LF}},
+		{name: "bom", md: "\xef\xbb\xbfa\r\nb", want: process.Format{BOM: true, LineEnding: process.CRLF}},
+		{name: "empty", md: "", want: process.Format{LineEnding: process.LF}},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// when
+			lines, format := process.SplitLines([]byte(tt.md))
+
+			// then
+			assert.Equal(t, tt.want, format)
+			processed := process.JoinLines(lines)
+			assert.NotContains(t, processed, "\r")
+			assert.Equal(t, tt.md, string(format.Apply([]byte(processed))))
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tahardi/pluckmd/internal/snip"
+)
+
+var (
+	ErrMalformedDirective = errors.New("malformed directive")
+	ErrUnsupportedKind    = errors.New("unsupported kind")
+	ErrInvalidRange       = errors.New("invalid range")
+	ErrUnclosedCodeBlock  = errors.New("unclosed code block")
+	ErrOrphanedEndMarker  = errors.New("orphaned end marker")
+
+	// NearMissRegex matches anything that looks like the start of a pluck
+	// directive, e.g., "<!-- pluck(", so that directives which fail to match
+	// PluckRegex can be reported instead of being ignored as plain text.
+	NearMissRegex = regexp.MustCompile(commentStart + optionalWs + pluckName + optionalWs + `\(`)
+)
+
+// Lint checks the directives in the markdown contents of file without
+// fetching anything. It reports directives that almost match PluckRegex,
+// kinds or doc comments that the lang does not support, invalid ranges,
+// directives that are followed by a code block that is never closed, and end
+// markers that do not close the region of a directive. All problems are
+// returned together as DiagnosticErrors.
+func Lint(file string, md []byte) error {
+	lines, _ := SplitLines(md)
+	blocks := ScanBlocks(lines)
+
+	var diagnostics DiagnosticErrors
+	endMarkers := make(map[int]bool)
+	for i := 0; i < len(lines); i++ {
+		if !blocks.IsComment(i) {
+			continue
+		}
+
+		if EndMarkerRegex.MatchString(lines[i]) {
+			if !endMarkers[i] {
+				diagnostics = append(diagnostics, NewDiagnosticError(
+					file,
+					i+1,
+					DirectiveColumn(lines[i]),
+					lines[i],
+					"",
+					fmt.Errorf(
+						"%w: it must directly follow a directive or its code block",
+						ErrOrphanedEndMarker,
+					),
+				))
+			}
+			continue
+		}
+
+		comment, ok := FindDirectiveComment(lines, i)
+		if !ok {
+			continue
+		}
+
+		for _, err := range LintDirective(lines, blocks, comment) {
+			diagnostics = append(diagnostics, NewDiagnosticError(
+				file,
+				comment.First+1,
+				DirectiveColumn(lines[comment.First]),
+				comment.Text,
+				"",
+				err,
+			))
+		}
+
+		// Only skip the rest of a comment that really is a directive, since a
+		// malformed one may have swallowed the lines of a valid one.
+		if ContainsPluckDirective(comment.Text) {
+			if marker, found := FindEndMarker(lines, blocks, comment.Last); found {
+				endMarkers[marker] = true
+			}
+			i = comment.Last
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+	return nil
+}
+
+// LintDirective returns every problem with the directive in comment.
+func LintDirective(
+	lines []string,
+	blocks *Blocks,
+	comment *DirectiveComment,
+) []error {
+	if !ContainsPluckDirective(comment.Text) {
+		return []error{fmt.Errorf(
+			"%w: %s",
+			ErrMalformedDirective,
+			MalformedReason(comment.Text),
+		)}
+	}
+
+	directive, err := parseDirective(comment.Text)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if !directive.Lang().Supports(directive.Kind()) {
+		errs = append(errs, fmt.Errorf(
+			"%w: lang %s does not support kind %s",
+			ErrUnsupportedKind,
+			directive.Lang(),
+			directive.Kind(),
+		))
+	}
+
+	if directive.Doc() && !directive.Lang().SupportsDoc(directive.Kind()) {
+		errs = append(errs, fmt.Errorf(
+			"%w: lang %s does not support doc comments for kind %s",
+			ErrUnsupportedKind,
+			directive.Lang(),
+			directive.Kind(),
+		))
+	}
+
+	selection, selected := directive.Selection()
+	switch {
+	case selected:
+		for _, r := range slices.Concat(selection.Lines, selection.Statements) {
+			if r.Start < 0 || r.Start >= r.End {
+				errs = append(errs, fmt.Errorf(
+					"%w: %d:%d: use 0 <= start < end with statements, markers, or several ranges",
+					ErrInvalidRange,
+					r.Start,
+					r.End,
+				))
+			}
+		}
+	case !ValidRange(directive.Start(), directive.End()):
+		errs = append(errs, fmt.Errorf(
+			"%w: %d, %d: use -1, -1 (empty), 0, 0 (full), or 0 <= start < end",
+			ErrInvalidRange,
+			directive.Start(),
+			directive.End(),
+		))
+	}
+
+	_, err = FindRegion(lines, blocks, comment, directive.Lang())
+	if err != nil {
+		errs = append(errs, fmt.Errorf(
+			"%w: the code block after the directive is never closed",
+			ErrUnclosedCodeBlock,
+		))
+	}
+	return errs
+}
+
+// MalformedReason guesses why line, which looks like a pluck directive, does
+// not match PluckRegex.
+func MalformedReason(line string) string {
+	comment := line
+	if loc := NearMissRegex.FindStringIndex(line); loc != nil {
+		comment = line[loc[0]:]
+	}
+	_, err := parseKeywordCall(comment)
+	switch {
+	case !strings.Contains(comment, commentEnd):
+		return "missing closing " + commentEnd
+	case strings.Contains(comment, "'"):
+		return "arguments must use double quotes"
+	case strings.Contains(comment, "=") && err != nil:
+		return err.Error()
+	default:
+		return `expected pluck("lang", "kind", "name", "source", start, end)`
+	}
+}
+
+// ValidRange reports whether start and end select the empty body, the full
+// body, or an ascending range of body lines.
+func ValidRange(start int, end int) bool {
+	switch {
+	case start == snip.EmptyStart && end == snip.EmptyEnd:
+		return true
+	case start == snip.FullStart && end == snip.FullEnd:
+		return true
+	default:
+		return start >= 0 && start < end
+	}
+}
@@ -0,0 +1,138 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      string
+		wantErr []error
+	}{
+		{
+			name: "valid - go function",
+			md:   "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0, 10) -->\n```go\n```\n",
+		},
+		{
+			name: "valid - yaml node",
+			md:   nonclaveMeasurementLine + "\n```yaml\n```\n",
+		},
+		{
+			name: "valid - not a directive",
+			md:   "<!-- just a comment -->\nplucking (things)\n",
+		},
+		{
+			name: "valid - example in code block",
+			md:   "~~~\n<!-- pluck(\"go\", \"function\") -->\n~~~\n",
+		},
+		{
+			name:    "invalid - missing argument",
+			md:      "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0) -->\n```go\n```\n",
+			wantErr: []error{process.ErrMalformedDirective},
+		},
+		{
+			name:    "invalid - missing argument with = in source",
+			md:      "<!-- pluck(\"go\", \"function\", \"Foo\", \"https://x?a=b\", 0) -->\n```go\n```\n",
+			wantErr: []error{process.ErrMalformedDirective},
+		},
+		{
+			name:    "invalid - single quotes",
+			md:      "<!-- pluck('go', 'function', 'Lint', 'lint.go', 0, 0) -->\n```go\n```\n",
+			wantErr: []error{process.ErrMalformedDirective},
+		},
+		{
+			name:    "invalid - unknown kind",
+			md:      "<!-- pluck(\"go\", \"not-a-kind\", \"Lint\", \"lint.go\", 0, 0) -->\n```go\n```\n",
+			wantErr: []error{process.ErrDirective},
+		},
+		{
+			name:    "invalid - unsupported kind",
+			md:      "<!-- pluck(\"yaml\", \"function\", \"Lint\", \"lint.yaml\", 0, 0) -->\n```yaml\n```\n",
+			wantErr: []error{process.ErrUnsupportedKind},
+		},
+		{
+			name:    "invalid - unsupported doc",
+			md:      "<!-- pluck(name=\"platform\", source=\"lint.yaml\", doc=true) -->\n```yaml\n```\n",
+			wantErr: []error{process.ErrUnsupportedKind},
+		},
+		{
+			name:    "invalid - selection range",
+			md:      "<!-- pluck(kind=\"function\", name=\"Lint\", source=\"lint.go\", statements=\"2:2\", lines=\"-1:-1\") -->\n```go\n```\n",
+			wantErr: []error{process.ErrInvalidRange, process.ErrInvalidRange},
+		},
+		{
+			name:    "invalid - descending range",
+			md:      "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 10, 2) -->\n```go\n```\n",
+			wantErr: []error{process.ErrInvalidRange},
+		},
+		{
+			name: "valid - missing code block",
+			md:   "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0, 0) -->\n\ntext\n",
+		},
+		{
+			name:    "invalid - unclosed code block",
+			md:      "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0, 0) -->\n~~~go\n```\n",
+			wantErr: []error{process.ErrUnclosedCodeBlock},
+		},
+		{
+			name: "invalid - several problems",
+			md:   "<!-- pluck(\"go\", \"node\", \"Lint\", \"lint.go\", -1, 3) -->\n```go\n",
+			wantErr: []error{
+				process.ErrUnsupportedKind,
+				process.ErrInvalidRange,
+				process.ErrUnclosedCodeBlock,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := process.Lint("README.md", []byte(tt.md))
+			if len(tt.wantErr) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var diagnostics process.DiagnosticErrors
+			require.ErrorAs(t, err, &diagnostics)
+			require.Len(t, diagnostics, len(tt.wantErr))
+			for i, wantErr := range tt.wantErr {
+				require.ErrorIs(t, diagnostics[i], wantErr)
+				assert.Equal(t, "README.md", diagnostics[i].File)
+				assert.Equal(t, 1, diagnostics[i].Line)
+			}
+		})
+	}
+
+	t.Run("valid - end marker", func(t *testing.T) {
+		// given
+		md := "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0, 0) -->\n```go\n```\n<!-- /pluck -->\n"
+
+		// when
+		err := process.Lint("README.md", []byte(md))
+
+		// then
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid - orphaned end marker", func(t *testing.T) {
+		// given
+		md := "<!-- pluck(\"go\", \"function\", \"Lint\", \"lint.go\", 0, 0) -->\n```go\n```\n\n" +
+			"## Heading\n<!-- /pluck -->\n"
+
+		// when
+		err := process.Lint("README.md", []byte(md))
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 1)
+		require.ErrorIs(t, diagnostics[0], process.ErrOrphanedEndMarker)
+		assert.Equal(t, 6, diagnostics[0].Line)
+	})
+}
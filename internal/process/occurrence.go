@@ -0,0 +1,55 @@
+package process
+
+// Occurrence is a directive found in a markdown file. Line and Column are
+// 1-based.
+type Occurrence struct {
+	File      string
+	Line      int
+	Column    int
+	Directive *Directive
+}
+
+// FindDirectives parses every directive in the markdown contents of file
+// without processing them. Directives that fail to parse are skipped and
+// returned together as DiagnosticErrors.
+func FindDirectives(file string, md []byte) ([]*Occurrence, error) {
+	lines, _ := SplitLines(md)
+	blocks := ScanBlocks(lines)
+
+	var occurrences []*Occurrence
+	var diagnostics DiagnosticErrors
+	for i := 0; i < len(lines); i++ {
+		if !blocks.IsComment(i) {
+			continue
+		}
+
+		comment, ok := FindDirectiveComment(lines, i)
+		if !ok || !ContainsPluckDirective(comment.Text) {
+			continue
+		}
+		i = comment.Last
+
+		line := comment.First + 1
+		column := DirectiveColumn(lines[comment.First])
+		directive, err := NewDirective(comment.Text)
+		if err != nil {
+			diagnostics = append(
+				diagnostics,
+				NewDiagnosticError(file, line, column, comment.Text, "", err),
+			)
+			continue
+		}
+
+		occurrences = append(occurrences, &Occurrence{
+			File:      file,
+			Line:      line,
+			Column:    column,
+			Directive: directive,
+		})
+	}
+
+	if len(diagnostics) > 0 {
+		return occurrences, diagnostics
+	}
+	return occurrences, nil
+}
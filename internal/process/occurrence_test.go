@@ -0,0 +1,54 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/pluck"
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+func TestFindDirectives(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		md := []byte("# Title\n\n" +
+			"  <!-- pluck(\"go\", \"type\", \"Processor\", \"./processor.go\", 0, 0) -->\n" +
+			"```go\n```\n\n" +
+			nonclaveMeasurementLine + "\n```yaml\n```\n")
+
+		// when
+		got, err := process.FindDirectives("README.md", md)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "README.md", got[0].File)
+		assert.Equal(t, 3, got[0].Line)
+		assert.Equal(t, 3, got[0].Column)
+		assert.Equal(t, pluck.Go, got[0].Directive.Lang())
+		assert.Equal(t, "Processor", got[0].Directive.Name())
+		assert.Equal(t, "./processor.go", got[0].Directive.Source())
+		assert.Equal(t, 7, got[1].Line)
+		assert.Equal(t, pluck.Node, got[1].Directive.Kind())
+	})
+
+	t.Run("error - invalid directive", func(t *testing.T) {
+		// given
+		md := []byte(
+			"<!-- pluck(\"go\", \"not-a-kind\", \"Name\", \"path\", 0, 0) -->\n" +
+				nonclaveMeasurementLine + "\n",
+		)
+
+		// when
+		got, err := process.FindDirectives("README.md", md)
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, 1, diagnostics[0].Line)
+		require.Len(t, got, 1)
+		assert.Equal(t, 2, got[0].Line)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/tahardi/pluckmd/internal/cache"
@@ -14,21 +15,27 @@ import (
 	"github.com/tahardi/pluckmd/internal/snip"
 )
 
-const (
-	GoCodeBlockStartLine   = "```go\n"
-	YAMLCodeBlockStartLine = "```yaml\n"
-	CodeBlockStopLine  = "```\n"
-)
-
 var (
 	ErrProcessor             = errors.New("processor")
 	ErrCodeBlockStopNotFound = errors.New("finding end of code block")
 )
 
 type Processor struct {
-	cacher   cache.Cacher
-	fetchers []fetch.Fetcher
-	pluckers map[pluck.Lang]pluck.Plucker
+	cacher    cache.Cacher
+	fetchers  []fetch.Fetcher
+	pluckers  map[pluck.Lang]pluck.Plucker
+	keepGoing bool
+	mu        sync.Mutex
+	inflight  map[string]*fetchCall
+}
+
+// fetchCall tracks a source code fetch that is in progress so that concurrent
+// requests for the same SourceCodeURI wait for it instead of fetching again.
+type fetchCall struct {
+	done       chan struct{}
+	sourceCode []byte
+	origin     Origin
+	err        error
 }
 
 func NewProcessor(
@@ -36,70 +43,190 @@ func NewProcessor(
 	fetchers []fetch.Fetcher,
 	pluckers map[pluck.Lang]pluck.Plucker,
 ) *Processor {
-	return &Processor{cacher: cacher, fetchers: fetchers, pluckers: pluckers}
+	return NewProcessorWithKeepGoing(cacher, fetchers, pluckers, false)
+}
+
+// NewProcessorWithKeepGoing returns a Processor that, if keepGoing is set,
+// does not stop at the first failing directive. See ProcessMarkdownFile.
+func NewProcessorWithKeepGoing(
+	cacher cache.Cacher,
+	fetchers []fetch.Fetcher,
+	pluckers map[pluck.Lang]pluck.Plucker,
+	keepGoing bool,
+) *Processor {
+	return &Processor{
+		cacher:    cacher,
+		fetchers:  fetchers,
+		pluckers:  pluckers,
+		keepGoing: keepGoing,
+		inflight:  make(map[string]*fetchCall),
+	}
+}
+
+func (p *Processor) KeepGoing() bool {
+	return p.keepGoing
 }
 
 func (p *Processor) ProcessMarkdown(
 	ctx context.Context,
 	md []byte,
 ) ([]byte, error) {
-	// Split markdown into lines. If the markdown ends with a newline, Split
-	// will return an empty string as the last element. This will cause us
-	// to write an extra newline to the output. Remove the ending newline
-	// (if any) so that we don't write an extra newline.
-	lines := strings.Split(strings.TrimSuffix(string(md), "\n"), "\n")
+	return p.ProcessMarkdownFile(ctx, "", md)
+}
+
+// ProcessMarkdownFile processes the markdown contents of file. Errors caused
+// by a directive are returned as a *DiagnosticError pointing at the directive.
+//
+// If the Processor was created with keepGoing, a failing directive leaves its
+// code block untouched and processing continues with the next directive. The
+// processed markdown is then returned together with DiagnosticErrors describing
+// every directive that failed.
+func (p *Processor) ProcessMarkdownFile(
+	ctx context.Context,
+	file string,
+	md []byte,
+) ([]byte, error) {
+	processed, _, err := p.ProcessMarkdownFileWithResults(ctx, file, md)
+	return processed, err
+}
+
+// ProcessMarkdownFileWithResults is like ProcessMarkdownFile, but also returns
+// a Result for every directive it processed.
+func (p *Processor) ProcessMarkdownFileWithResults(
+	ctx context.Context,
+	file string,
+	md []byte,
+) ([]byte, []*Result, error) {
+	// Every line is written with a newline, so the line endings, BOM, and
+	// final newline of md are restored once processing is done.
+	lines, format := SplitLines(md)
+	blocks := ScanBlocks(lines)
 
 	var processed bytes.Buffer
+	var results []*Result
+	var diagnostics DiagnosticErrors
 	for i := 0; i < len(lines); i++ {
-		processed.WriteString(lines[i] + "\n")
-		if !ContainsPluckDirective(lines[i]) {
+		if !blocks.IsComment(i) {
+			processed.WriteString(lines[i] + "\n")
 			continue
 		}
 
-		directiveLine := lines[i]
-		directive, err := NewDirective(directiveLine)
-		if err != nil {
-			return nil, fmt.Errorf("%w: creating directive: %w", ErrProcessor, err)
+		comment, ok := FindDirectiveComment(lines, i)
+		if !ok || !ContainsPluckDirective(comment.Text) {
+			processed.WriteString(lines[i] + "\n")
+			continue
 		}
 
-		snippet, err := p.GetCodeSnippet(ctx, directive)
-		if err != nil {
-			return nil, fmt.Errorf("%w: getting snippet: %w", ErrProcessor, err)
-		}
+		processed.WriteString(JoinLines(lines[comment.First : comment.Last+1]))
+		i = comment.Last
 
-		codeBlockStartLine := ""
-		switch directive.Lang() {
-		case pluck.Go:
-			codeBlockStartLine = GoCodeBlockStartLine
-		case pluck.YAML:
-			codeBlockStartLine = YAMLCodeBlockStartLine
-		}
-		err = WriteCodeBlock(&processed, directiveLine, codeBlockStartLine, snippet)
-		if err != nil {
-			return nil, fmt.Errorf("%w: writing code block: %w", ErrProcessor, err)
+		result := &Result{
+			File:   file,
+			Line:   comment.First + 1,
+			Column: DirectiveColumn(lines[comment.First]),
+			Text:   strings.TrimSpace(comment.Text),
 		}
+		results = append(results, result)
 
-		end, err := FindCodeBlockEnd(codeBlockStartLine, lines, i)
+		codeBlock, end, err := p.ProcessDirective(ctx, result, lines, blocks, comment)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"%w: %w: snippet uri: %s",
-				ErrProcessor,
-				err,
-				directive.CodeSnippetURI(),
-			)
+			result.Error = ErrorMessage(err)
+			if !p.keepGoing {
+				return nil, results, err
+			}
+			diagnostics = append(diagnostics, ToDiagnosticErrors(file, err)...)
+			continue
 		}
+
+		result.Changed = codeBlock != JoinLines(lines[i+1:end+1])
+		processed.WriteString(codeBlock)
 		i = end
 	}
-	return processed.Bytes(), nil
+
+	if len(diagnostics) > 0 {
+		return format.Apply(processed.Bytes()), results, diagnostics
+	}
+	return format.Apply(processed.Bytes()), results, nil
+}
+
+// ProcessDirective processes the directive in comment. It returns the new
+// code block to write after the comment and the index of the last line of
+// the region it replaces. See FindRegion. The parsed directive and the origin
+// of its source code are recorded in result.
+func (p *Processor) ProcessDirective(
+	ctx context.Context,
+	result *Result,
+	lines []string,
+	blocks *Blocks,
+	comment *DirectiveComment,
+) (string, int, error) {
+	directive, err := NewDirective(comment.Text)
+	if err != nil {
+		return "", 0, NewDiagnosticError(
+			result.File,
+			result.Line,
+			result.Column,
+			comment.Text,
+			"",
+			fmt.Errorf("%w: creating directive: %w", ErrProcessor, err),
+		)
+	}
+	result.Directive = directive
+
+	diagnose := func(err error) *DiagnosticError {
+		return NewDiagnosticError(
+			result.File,
+			result.Line,
+			result.Column,
+			comment.Text,
+			directive.CodeSnippetURI(),
+			err,
+		)
+	}
+
+	region, err := FindRegion(lines, blocks, comment, directive.Lang())
+	if err != nil {
+		return "", 0, diagnose(fmt.Errorf("%w: %w", ErrProcessor, err))
+	}
+
+	snippet, origin, err := p.GetCodeSnippet(ctx, directive)
+	result.Fetcher = origin.Fetcher
+	result.Cached = origin.Cached
+	if err != nil {
+		return "", 0, diagnose(
+			fmt.Errorf("%w: getting snippet: %w", ErrProcessor, err),
+		)
+	}
+
+	var codeBlock bytes.Buffer
+	codeBlock.WriteString(region.Blank)
+	err = WriteCodeBlock(
+		&codeBlock,
+		lines[comment.First],
+		ChooseFence(region.Marker, snippet),
+		region.Info,
+		snippet,
+	)
+	if err != nil {
+		return "", 0, diagnose(
+			fmt.Errorf("%w: writing code block: %w", ErrProcessor, err),
+		)
+	}
+	return codeBlock.String(), region.Last, nil
 }
 
 func (p *Processor) GetCodeSnippet(
 	ctx context.Context,
 	directive *Directive,
-) (string, error) {
-	fullSnippet, err := p.GetFullCodeSnippet(ctx, directive)
+) (string, Origin, error) {
+	fullSnippet, origin, err := p.GetFullCodeSnippet(ctx, directive)
 	if err != nil {
-		return "", err
+		return "", origin, err
+	}
+
+	// Signatures have no body to select lines from.
+	if directive.Kind() == pluck.Signature {
+		return fullSnippet, origin, nil
 	}
 
 	var snipper snip.Snipper
@@ -107,89 +234,164 @@ func (p *Processor) GetCodeSnippet(
 	case pluck.Go:
 		snipper, err = snip.NewGoSnipper(directive.Name(), fullSnippet)
 		if err != nil {
-			return "", fmt.Errorf("%w: creating go snipper: %w", ErrProcessor, err)
+			return "", origin, fmt.Errorf("%w: creating go snipper: %w", ErrProcessor, err)
 		}
 	case pluck.YAML:
 		snipper, err = snip.NewYAMLSnipper(directive.Name(), fullSnippet)
 		if err != nil {
-			return "", fmt.Errorf("%w: creating yaml snipper: %w", ErrProcessor, err)
+			return "", origin, fmt.Errorf("%w: creating yaml snipper: %w", ErrProcessor, err)
 		}
 	default:
-		return "", fmt.Errorf("%w: unsupported lang: %s", ErrProcessor, directive.Lang())
+		return "", origin, fmt.Errorf("%w: unsupported lang: %s", ErrProcessor, directive.Lang())
+	}
+
+	selection, selected := directive.Selection()
+	if !selected {
+		snippet, err := snipper.Snippet(directive.start, directive.end)
+		return snippet, origin, err
 	}
-	return snipper.Snippet(directive.start, directive.end)
+
+	selectionSnipper, ok := snipper.(snip.SelectionSnipper)
+	if !ok {
+		return "", origin, fmt.Errorf(
+			"%w: lang %s does not support statements, markers, or several ranges",
+			ErrProcessor,
+			directive.Lang(),
+		)
+	}
+	snippet, err := selectionSnipper.SnippetWithSelection(selection)
+	return snippet, origin, err
 }
 
 func (p *Processor) GetFullCodeSnippet(
 	ctx context.Context,
 	directive *Directive,
-) (string, error) {
+) (string, Origin, error) {
 	snippetBytes, err := p.cacher.Retrieve(ctx, directive.CodeSnippetURI())
 	switch {
 	case err == nil:
-		return string(snippetBytes), nil
+		return string(snippetBytes), Origin{Cached: true}, nil
 	case errors.Is(err, cache.ErrURINotFound):
 		break
 	default:
-		return "", fmt.Errorf(
+		return "", Origin{}, fmt.Errorf(
 			"%w: retrieving snippet bytes: %w",
 			ErrProcessor,
 			err,
 		)
 	}
 
-	sourceCode, err := p.GetSourceCode(ctx, directive)
+	sourceCode, origin, err := p.GetSourceCode(ctx, directive)
 	if err != nil {
-		return "", err
+		return "", origin, err
 	}
 
 	plucker, exists := p.pluckers[directive.Lang()]
 	if !exists {
-		return "", fmt.Errorf("%w: no plucker for lang: %s", ErrProcessor, directive.Lang())
+		return "", origin, fmt.Errorf("%w: no plucker for lang: %s", ErrProcessor, directive.Lang())
 	}
 
-	snippetString, err := plucker.Pluck(
+	pluckFunc := plucker.Pluck
+	if directive.Doc() {
+		docPlucker, ok := plucker.(pluck.DocPlucker)
+		if !ok {
+			return "", origin, fmt.Errorf(
+				"%w: plucker for lang %s does not support doc comments",
+				ErrProcessor,
+				directive.Lang(),
+			)
+		}
+		pluckFunc = docPlucker.PluckWithDoc
+	}
+
+	snippetString, err := pluckFunc(
 		ctx,
 		string(sourceCode),
 		directive.Name(),
 		directive.Kind(),
 	)
 	if err != nil {
-		return "", fmt.Errorf("%w: plucking snippet: %w", ErrProcessor, err)
+		return "", origin, fmt.Errorf("%w: plucking snippet: %w", ErrProcessor, err)
 	}
 
 	err = p.cacher.Store(ctx, directive.CodeSnippetURI(), []byte(snippetString))
 	if err != nil {
-		return "", fmt.Errorf(
+		return "", origin, fmt.Errorf(
 			"%w: storing snippet bytes: %w",
 			ErrProcessor,
 			err,
 		)
 	}
-	return snippetString, nil
+	return snippetString, origin, nil
 }
 
+// GetSourceCode returns the source code of directive from the cache, or
+// fetches it if it is not cached. Concurrent calls for the same source share
+// a single fetch and report the fetcher that made it.
 func (p *Processor) GetSourceCode(
 	ctx context.Context,
 	directive *Directive,
-) ([]byte, error) {
-	sourceCode, err := p.cacher.Retrieve(ctx, directive.SourceCodeURI())
-	switch {
-	case err == nil:
-		return sourceCode, nil
-	case errors.Is(err, cache.ErrURINotFound):
-		break
-	default:
-		return nil, fmt.Errorf(
-			"%w: retrieving source bytes: %w",
-			ErrProcessor,
-			err,
-		)
+) ([]byte, Origin, error) {
+	uri := directive.SourceCodeURI()
+
+	// Check for an in-flight fetch and the cache while holding the lock. The
+	// fetching goroutine stores the source code in the cache before removing
+	// its call, so we are guaranteed to see one or the other.
+	p.mu.Lock()
+	call, inflight := p.inflight[uri]
+	if !inflight {
+		sourceCode, err := p.cacher.Retrieve(ctx, uri)
+		switch {
+		case err == nil:
+			p.mu.Unlock()
+			return sourceCode, Origin{Cached: true}, nil
+		case errors.Is(err, cache.ErrURINotFound):
+			break
+		default:
+			p.mu.Unlock()
+			return nil, Origin{}, fmt.Errorf(
+				"%w: retrieving source bytes: %w",
+				ErrProcessor,
+				err,
+			)
+		}
+
+		call = &fetchCall{done: make(chan struct{})}
+		p.inflight[uri] = call
+	}
+	p.mu.Unlock()
+
+	if inflight {
+		select {
+		case <-call.done:
+			return call.sourceCode, call.origin, call.err
+		case <-ctx.Done():
+			return nil, Origin{}, fmt.Errorf(
+				"%w: waiting for source bytes: %w",
+				ErrProcessor,
+				ctx.Err(),
+			)
+		}
 	}
 
-	sourceCode, err = p.Fetch(ctx, directive)
+	call.sourceCode, call.origin, call.err = p.FetchAndStore(ctx, directive)
+
+	p.mu.Lock()
+	delete(p.inflight, uri)
+	p.mu.Unlock()
+	close(call.done)
+	return call.sourceCode, call.origin, call.err
+}
+
+// FetchAndStore fetches the source code of directive, stores it in the cache,
+// and returns it together with its origin.
+func (p *Processor) FetchAndStore(
+	ctx context.Context,
+	directive *Directive,
+) ([]byte, Origin, error) {
+	sourceCode, origin, err := p.Fetch(ctx, directive)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, Origin{}, fmt.Errorf(
 			"%w: fetching source bytes: %w",
 			ErrProcessor,
 			err,
@@ -198,21 +400,27 @@ func (p *Processor) GetSourceCode(
 
 	err = p.cacher.Store(ctx, directive.SourceCodeURI(), sourceCode)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, origin, fmt.Errorf(
 			"%w: storing source bytes: %w",
 			ErrProcessor,
 			err,
 		)
 	}
-	return sourceCode, nil
+	return sourceCode, origin, nil
 }
 
-func (p *Processor) Fetch(ctx context.Context, directive *Directive) ([]byte, error) {
+// Fetch tries each fetcher in order and returns the source code of directive
+// from the first one that succeeds, together with that fetcher's name and
+// whether the fetcher served it from its own cache.
+func (p *Processor) Fetch(
+	ctx context.Context,
+	directive *Directive,
+) ([]byte, Origin, error) {
 	errs := []error{}
 	for _, fetcher := range p.fetchers {
-		sourceCode, err := fetcher.Fetch(ctx, directive.SourceCodeURI())
+		sourceCode, cached, err := fetch.Cached(ctx, fetcher, directive.SourceCodeURI())
 		if err == nil {
-			return sourceCode, nil
+			return sourceCode, Origin{Fetcher: fetch.Name(fetcher), Cached: cached}, nil
 		}
 		errs = append(errs, fmt.Errorf(
 			"%w: fetching source bytes: %w",
@@ -220,13 +428,16 @@ func (p *Processor) Fetch(ctx context.Context, directive *Directive) ([]byte, er
 			err),
 		)
 	}
-	return nil, errors.Join(errs...)
+	return nil, Origin{}, errors.Join(errs...)
 }
 
+// WriteCodeBlock writes code to processed as a code block opened by fence
+// and info, e.g., "```" and "go", and indented like directiveLine.
 func WriteCodeBlock(
 	processed *bytes.Buffer,
 	directiveLine string,
-	codeBlockStartLine string,
+	fence string,
+	info string,
 	code string,
 ) error {
 	// Calculate indentation by trimming whitespace from the left side of the
@@ -241,20 +452,20 @@ func WriteCodeBlock(
 		indent = directiveLine[:len(directiveLine)-len(trimmed)]
 	}
 
-	if indent == "" {
-		processed.WriteString(codeBlockStartLine)
-		processed.WriteString(code)
-		processed.WriteString(CodeBlockStopLine)
-		return nil
-	}
-
-	indentedCode := IndentCode(code, indent)
-	processed.WriteString(indent + codeBlockStartLine)
-	processed.WriteString(indentedCode)
-	processed.WriteString(indent + CodeBlockStopLine)
+	processed.WriteString(indent + fence + info + "\n")
+	processed.WriteString(IndentCode(code, indent))
+	processed.WriteString(indent + fence + "\n")
 	return nil
 }
 
+// JoinLines joins lines back into text, ending each line with a newline.
+func JoinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
 func IndentCode(code string, indentation string) string {
 	if indentation == "" {
 		return code
@@ -269,19 +480,23 @@ func IndentCode(code string, indentation string) string {
 	return strings.Join(lines, "\n") + "\n"
 }
 
-func FindCodeBlockEnd(codeBlockStartLine string, lines []string, i int) (int, error) {
-	foundStart := false
-	for ; i < len(lines); i++ {
-		trimmed := strings.TrimLeftFunc(lines[i], unicode.IsSpace) + "\n"
-		if !foundStart {
-			if strings.HasPrefix(trimmed, codeBlockStartLine) {
-				foundStart = true
-			}
-		} else {
-			if strings.HasSuffix(trimmed, CodeBlockStopLine) {
-				return i, nil
-			}
+// ChooseFence returns a fence for a code block holding code. It keeps the
+// character and length of marker, the existing fence if any, but makes the
+// fence longer than any fence inside code so that code cannot close it.
+func ChooseFence(marker string, code string) string {
+	char := BacktickFence
+	length := MinFenceLength
+	if marker != "" {
+		char = marker[:1]
+		length = max(length, len(marker))
+	}
+
+	for line := range strings.SplitSeq(code, "\n") {
+		_, content := Indentation(line)
+		run := len(content) - len(strings.TrimLeft(content, char))
+		if run >= length {
+			length = run + 1
 		}
 	}
-	return len(lines), ErrCodeBlockStopNotFound
+	return strings.Repeat(char, length)
 }
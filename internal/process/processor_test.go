@@ -3,7 +3,10 @@ package process_test
 import (
 	"context"
 	_ "embed"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,8 +21,14 @@ import (
 const (
 	processorCodeSnippetURI = "./processor.go.type.Processor"
 	processorSourceCodeURI  = "./processor.go"
+	nonclaveSourceCodeURI   = "./testdata/nonclave-sev.yaml"
+	missingSourceLine       = `<!-- pluck("yaml", "file", "missing.yaml", "./testdata/missing.yaml", 0, 0) -->`
+	nonclaveMeasurementLine = `<!-- pluck("yaml", "node", "nonclave.measurement", "./testdata/nonclave-sev.yaml", 0, 0) -->`
 )
 
+//go:embed testdata/nonclave-sev.yaml
+var nonclaveSEVYAML []byte
+
 //go:embed testdata/processed.md
 var processedMD []byte
 
@@ -173,3 +182,533 @@ func TestProcessor_ProcessMarkdown(t *testing.T) {
 		assert.ErrorIs(t, err, assert.AnError)
 	})
 }
+
+func TestProcessor_GetSourceCode(t *testing.T) {
+	t.Run("happy path - concurrent fetches are deduplicated", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		numWorkers := 8
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher := mocks.NewFetcher(t)
+		fetcher.On("Fetch", ctx, nonclaveSourceCodeURI).
+			After(50*time.Millisecond).
+			Return(nonclaveSEVYAML, nil).
+			Once()
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		directive, err := process.NewDirective(nonclaveMeasurementLine)
+		require.NoError(t, err)
+
+		// when
+		got := make([][]byte, numWorkers)
+		errs := make([]error, numWorkers)
+		var wg sync.WaitGroup
+		for i := range numWorkers {
+			wg.Go(func() {
+				got[i], _, errs[i] = processor.GetSourceCode(ctx, directive)
+			})
+		}
+		wg.Wait()
+
+		// then
+		for i := range numWorkers {
+			require.NoError(t, errs[i])
+			assert.YAMLEq(t, string(nonclaveSEVYAML), string(got[i]))
+		}
+	})
+
+	t.Run("happy path - cached by fetcher", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcherCacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+		err = fetcherCacher.Store(ctx, nonclaveSourceCodeURI, nonclaveSEVYAML)
+		require.NoError(t, err)
+		local, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+		fetcher, err := fetch.NewCachingFetcher(fetcherCacher, local)
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		directive, err := process.NewDirective(nonclaveMeasurementLine)
+		require.NoError(t, err)
+
+		// when
+		_, origin, err := processor.GetSourceCode(ctx, directive)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, process.Origin{Fetcher: fetch.LocalFetcherName, Cached: true}, origin)
+	})
+}
+
+func TestProcessor_ProcessMarkdownFile(t *testing.T) {
+	t.Run("error - diagnostic points at directive", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		file := "docs/README.md"
+		md := []byte("# Title\n\n  " + missingSourceLine + "\n  ```yaml\n  ```\n")
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		_, err = processor.ProcessMarkdownFile(ctx, file, md)
+
+		// then
+		var diagnostic *process.DiagnosticError
+		require.ErrorAs(t, err, &diagnostic)
+		assert.Equal(t, file, diagnostic.File)
+		assert.Equal(t, 3, diagnostic.Line)
+		assert.Equal(t, 3, diagnostic.Column)
+		assert.Equal(t, missingSourceLine, diagnostic.Directive)
+		assert.Equal(t, "./testdata/missing.yaml.file.missing.yaml", diagnostic.URI)
+		assert.True(t, strings.HasPrefix(err.Error(), "docs/README.md:3:3: "))
+	})
+
+	t.Run("happy path - keep going", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		staleBlock := "```yaml\nstale\n```\n"
+		md := []byte(missingSourceLine + "\n" + staleBlock +
+			nonclaveMeasurementLine + "\n```yaml\n```\n" +
+			missingSourceLine + "\n" + staleBlock)
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessorWithKeepGoing(cacher, fetchers, pluckers, true)
+
+		// when
+		got, err := processor.ProcessMarkdownFile(ctx, "README.md", md)
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 2)
+		assert.Equal(t, 1, diagnostics[0].Line)
+		assert.Equal(t, 8, diagnostics[1].Line)
+
+		assert.True(t, strings.HasPrefix(string(got), missingSourceLine+"\n"+staleBlock))
+		assert.True(t, strings.HasSuffix(string(got), missingSourceLine+"\n"+staleBlock))
+		assert.Contains(t, string(got), "measurement: |")
+	})
+
+	t.Run("happy path - multi-line directives", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := "  <!--\n" +
+			"    pluck(\n" +
+			"      name=\"platform\",\n" +
+			"      source=\"./testdata/nonclave-sev.yaml\",\n" +
+			"    )\n" +
+			"  -->\n"
+		positional := "<!-- pluck(\n" +
+			"  \"yaml\", \"node\", \"platform\", \"./testdata/nonclave-sev.yaml\",\n" +
+			"  0, 0) -->\n"
+		md := []byte(directive + "  ```yaml\n  ```\n" + positional + "```yaml\nstale\n```\n")
+		want := directive + "  ```yaml\n  platform: \"sev\"\n  ```\n" +
+			positional + "```yaml\nplatform: \"sev\"\n```\n"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, results, err := processor.ProcessMarkdownFileWithResults(ctx, "README.md", md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+		require.Len(t, results, 2)
+		assert.Equal(t, 1, results[0].Line)
+		assert.Equal(t, 3, results[0].Column)
+		assert.Equal(t, 9, results[1].Line)
+	})
+
+	t.Run("happy path - ignores directives in code blocks", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->` + "\n"
+		example := "~~~markdown\n" + directive + "```yaml\n```\n~~~\n" +
+			"\n    " + directive
+		md := []byte(example + "\n" + directive + "````yaml\nstale\n````\n")
+		want := example + "\n" + directive + "````yaml\nplatform: \"sev\"\n````\n"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, results, err := processor.ProcessMarkdownFileWithResults(ctx, "README.md", md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+		require.Len(t, results, 1)
+		assert.Equal(t, 9, results[0].Line)
+	})
+
+	t.Run("happy path - ignores directives in raw html", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->` + "\n"
+		md := []byte("<pre>\n" + directive + "\n</pre>\n")
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, results, err := processor.ProcessMarkdownFileWithResults(ctx, "README.md", md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, string(md), string(got))
+		assert.Empty(t, results)
+	})
+
+	t.Run("happy path - creates and replaces code blocks", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->` + "\n"
+		block := "```yaml\nplatform: \"sev\"\n```\n"
+		md := []byte(directive + "\nSome text.\n" +
+			directive + "\n~~~go\nstale\n~~~\nMore text.\n" +
+			directive)
+		want := directive + block + "\nSome text.\n" +
+			directive + "\n~~~yaml\nplatform: \"sev\"\n~~~\nMore text.\n" +
+			directive + block
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, results, err := processor.ProcessMarkdownFileWithResults(ctx, "README.md", md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+		require.Len(t, results, 3)
+		for _, result := range results {
+			assert.True(t, result.Changed)
+		}
+	})
+
+	t.Run("happy path - end marker", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->` + "\n"
+		endMarker := "<!-- /pluck -->\n"
+		md := []byte("<details>\n\n" + directive + "\n~~~go\nstale\n~~~\n\n" + endMarker + "</details>\n" +
+			directive + "```yaml\n```\n" + directive + endMarker)
+		want := "<details>\n\n" + directive + "~~~yaml\nplatform: \"sev\"\n~~~\n" + endMarker + "</details>\n" +
+			directive + "```yaml\nplatform: \"sev\"\n```\n" + directive + "```yaml\nplatform: \"sev\"\n```\n" + endMarker
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("happy path - stray end marker", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->` + "\n"
+		rest := "## Heading\n\nSome text.\n<!-- pluck('yaml') -->\n<!-- /pluck -->\n"
+		md := []byte(directive + "```yaml\nstale\n```\n" + rest)
+		want := directive + "```yaml\nplatform: \"sev\"\n```\n" + rest
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("happy path - preserves line endings and bom", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck("yaml", "node", "platform", "./testdata/nonclave-sev.yaml", 0, 0) -->`
+		md := []byte("\xef\xbb\xbf# Title\r\n" + directive)
+		want := "\xef\xbb\xbf# Title\r\n" + directive + "\r\n```yaml\r\nplatform: \"sev\"\r\n```"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		yamlPlucker, err := pluck.NewYAMLPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+		require.NoError(t, err)
+		again, err := processor.ProcessMarkdown(ctx, got)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+		assert.Equal(t, want, string(again))
+	})
+
+	t.Run("happy path - doc comments", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck(kind="function", name="NewProcessorWithKeepGoing", source="./processor.go", lines="-1:-1", doc=true) -->` + "\n"
+		md := []byte(directive + "```go\n```\n")
+		want := directive + "```go\n" +
+			"// NewProcessorWithKeepGoing returns a Processor that, if keepGoing is set,\n" +
+			"// does not stop at the first failing directive. See ProcessMarkdownFile.\n" +
+			"func NewProcessorWithKeepGoing(\n" +
+			"\tcacher cache.Cacher,\n" +
+			"\tfetchers []fetch.Fetcher,\n" +
+			"\tpluckers map[pluck.Lang]pluck.Plucker,\n" +
+			"\tkeepGoing bool,\n" +
+			") *Processor {\n" +
+			"\t// ...\n" +
+			"}\n" +
+			"```\n"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		goPlucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.Go: goPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("happy path - signature", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck(kind="signature", name="NewProcessorWithKeepGoing", source="./processor.go") -->` + "\n"
+		md := []byte(directive + "```go\n```\n")
+		want := directive + "```go\n" +
+			"func NewProcessorWithKeepGoing(cacher cache.Cacher, fetchers []fetch.Fetcher, " +
+			"pluckers map[pluck.Lang]pluck.Plucker, keepGoing bool) *Processor\n" +
+			"```\n"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		goPlucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.Go: goPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("happy path - statements", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck(kind="function", name="Processor.ProcessMarkdownFile", source="./processor.go", statements="1:2") -->` + "\n"
+		md := []byte(directive + "```go\n```\n")
+		want := directive + "```go\n" +
+			"func (p *Processor) ProcessMarkdownFile(\n" +
+			"\tctx context.Context,\n" +
+			"\tfile string,\n" +
+			"\tmd []byte,\n" +
+			") ([]byte, error) {\n" +
+			"\t// ...\n" +
+			"\treturn processed, err\n" +
+			"}\n" +
+			"```\n"
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		goPlucker, err := pluck.NewGoPlucker()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.Go: goPlucker}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		got, err := processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	})
+
+	t.Run("error - plucker does not support doc comments", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		directive := `<!-- pluck(kind="type", name="Processor", source="./processor.go", doc=true) -->`
+		md := []byte(directive + "\n```go\n```\n")
+
+		cacher, err := cache.NewRAMCacher()
+		require.NoError(t, err)
+
+		fetcher, err := fetch.NewLocalFetcher()
+		require.NoError(t, err)
+
+		fetchers := []fetch.Fetcher{fetcher}
+		pluckers := map[pluck.Lang]pluck.Plucker{pluck.Go: mocks.NewPlucker(t)}
+		processor := process.NewProcessor(cacher, fetchers, pluckers)
+
+		// when
+		_, err = processor.ProcessMarkdown(ctx, md)
+
+		// then
+		require.ErrorIs(t, err, process.ErrProcessor)
+		assert.ErrorContains(t, err, "does not support doc comments")
+	})
+}
+
+func TestChooseFence(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker string
+		code   string
+		want   string
+	}{
+		{name: "new block", marker: "", code: "a := 1\n", want: "```"},
+		{name: "keeps existing fence", marker: "~~~~", code: "a := 1\n", want: "~~~~"},
+		{name: "outgrows fence in code", marker: "```", code: "```go\nx\n````\n", want: "`````"},
+		{name: "ignores other fence character", marker: "~~~", code: "```\n", want: "~~~"},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// when
+			got := process.ChooseFence(tt.marker, tt.code)
+
+			// then
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
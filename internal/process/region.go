@@ -0,0 +1,77 @@
+package process
+
+import (
+	"github.com/tahardi/pluckmd/internal/pluck"
+)
+
+// Region is the part of a markdown document managed by a directive. It runs
+// from the line after the directive comment through Last, and is replaced by
+// Blank followed by a code block opened with Marker and Info.
+type Region struct {
+	// Last is the last line of the directive comment if the region is empty.
+	Last   int
+	Blank  string
+	Marker string
+	Info   string
+}
+
+// FindRegion returns the region managed by the directive in comment.
+//
+// If an end marker, "<!-- /pluck -->", closes the code block that follows the
+// directive, the region runs up to it. See FindEndMarker. Otherwise, the
+// region is the fenced code block that follows the directive, with only blank
+// lines in between, or empty if there is none, in which case a code block is
+// inserted. Either way, the code block keeps the fence of an existing block,
+// and its info string if it is for lang.
+func FindRegion(
+	lines []string,
+	blocks *Blocks,
+	comment *DirectiveComment,
+	lang pluck.Lang,
+) (*Region, error) {
+	region := &Region{Last: comment.Last, Info: string(lang)}
+	fence, ok := FindCodeBlock(blocks, comment.Last)
+	if ok && fence.Close == -1 {
+		return nil, ErrCodeBlockStopNotFound
+	}
+
+	if marker, found := FindEndMarker(lines, blocks, comment.Last); found {
+		region.Last = marker - 1
+	} else if ok {
+		region.Last = fence.Close
+		region.Blank = JoinLines(lines[comment.Last+1 : fence.Open])
+	}
+
+	if ok {
+		region.Marker = fence.Marker
+		if fence.Lang() == region.Info {
+			region.Info = fence.Info
+		}
+	}
+	return region, nil
+}
+
+// FindCodeBlock returns the fenced code block that opens on the first
+// non-blank line after lines[i], if any.
+func FindCodeBlock(blocks *Blocks, i int) (*Fence, bool) {
+	return blocks.FenceAt(blocks.NextNonBlank(i))
+}
+
+// FindEndMarker returns the index of the end marker that closes the region
+// of a directive ending on lines[i]. Only blank lines and the fenced code
+// block of the directive may come in between, so that a stray end marker
+// further down never claims the content before it.
+func FindEndMarker(lines []string, blocks *Blocks, i int) (int, bool) {
+	next := blocks.NextNonBlank(i)
+	if fence, ok := blocks.FenceAt(next); ok {
+		if fence.Close == -1 {
+			return 0, false
+		}
+		next = blocks.NextNonBlank(fence.Close)
+	}
+
+	if next < len(lines) && blocks.IsComment(next) && EndMarkerRegex.MatchString(lines[next]) {
+		return next, true
+	}
+	return 0, false
+}
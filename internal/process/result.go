@@ -0,0 +1,37 @@
+package process
+
+import "errors"
+
+// Result describes what happened to a single directive: where it is, what it
+// asked for, where its source code came from, and whether its code block was
+// changed. Error is empty if the directive was processed successfully.
+type Result struct {
+	File      string     `json:"file"`
+	Line      int        `json:"line"`
+	Column    int        `json:"column"`
+	Text      string     `json:"text"`
+	Directive *Directive `json:"directive,omitempty"`
+	Fetcher   string     `json:"fetcher,omitempty"`
+	Cached    bool       `json:"cached"`
+	Changed   bool       `json:"changed"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Origin records where the source code of a snippet came from. Fetcher is the
+// name of the fetcher that fetched it, and is empty if it came from the
+// processor's cache. Cached is also set if the fetcher served it from a cache
+// of its own, e.g., the persistent cache of GitHub sources.
+type Origin struct {
+	Fetcher string
+	Cached  bool
+}
+
+// ErrorMessage returns the message of err without the position prefix that
+// a DiagnosticError adds, since a Result already records the position.
+func ErrorMessage(err error) string {
+	var diagnostic *DiagnosticError
+	if errors.As(err, &diagnostic) {
+		return diagnostic.Err.Error()
+	}
+	return err.Error()
+}
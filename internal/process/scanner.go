@@ -0,0 +1,350 @@
+package process
+
+import (
+	"strings"
+)
+
+const (
+	BacktickFence  = "`"
+	TildeFence     = "~"
+	MinFenceLength = 3
+	CodeIndent     = 4
+	TabWidth       = 4
+)
+
+// RawHTMLTags are the tags that start a raw HTML block, CommonMark's type 1
+// HTML block, whose contents are passed through untouched until a line with
+// the closing tag of any of them.
+var RawHTMLTags = []string{"pre", "script", "style", "textarea"}
+
+type LineKind int
+
+const (
+	TextLine LineKind = iota
+	BlankLine
+	// FenceLine is the opening or closing fence of a fenced code block.
+	FenceLine
+	// CodeLine is a line inside a fenced or indented code block.
+	CodeLine
+	// CommentLine is the first line of an HTML comment at block level.
+	CommentLine
+	// CommentBodyLine is any other line of an HTML comment at block level.
+	CommentBodyLine
+	// HTMLLine is a line of a raw HTML block, e.g., a <pre> element.
+	HTMLLine
+)
+
+// Fence is a fenced code block. Open and Close are the indexes of its opening
+// and closing fence lines. Close is -1 if the block is never closed, in which
+// case it runs to the end of its container or the document.
+type Fence struct {
+	Open   int
+	Close  int
+	Marker string
+	Info   string
+
+	// column is where the container the fence is in starts.
+	column int
+}
+
+// Lang returns the first word of the fence's info string, e.g., "go" for
+// "```go title=main.go".
+func (f *Fence) Lang() string {
+	fields := strings.Fields(f.Info)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// Blocks records what kind of markdown block each line of a document belongs
+// to, following the CommonMark block structure closely enough to tell code
+// from directives: fenced code blocks (with "```" or "~~~" fences of any
+// length), indented code blocks, HTML comments, raw HTML blocks such as
+// <pre> elements, and the list items that change how much indentation makes
+// a line code. Block quotes and other HTML blocks are treated as plain text.
+type Blocks struct {
+	kinds  []LineKind
+	fences []*Fence
+}
+
+// ScanBlocks scans the lines of a markdown document.
+func ScanBlocks(lines []string) *Blocks {
+	s := &blockScanner{
+		blocks: &Blocks{kinds: make([]LineKind, len(lines))},
+	}
+	for i, line := range lines {
+		s.blocks.kinds[i] = s.scan(i, line)
+	}
+	return s.blocks
+}
+
+func (b *Blocks) Kind(i int) LineKind {
+	return b.kinds[i]
+}
+
+// IsComment reports whether lines[i] starts an HTML comment at block level,
+// i.e., a comment that is not inside a code block or a paragraph.
+func (b *Blocks) IsComment(i int) bool {
+	return b.kinds[i] == CommentLine
+}
+
+func (b *Blocks) Fences() []*Fence {
+	return b.fences
+}
+
+// FenceAt returns the fenced code block whose opening fence is lines[i].
+func (b *Blocks) FenceAt(i int) (*Fence, bool) {
+	for _, fence := range b.fences {
+		if fence.Open == i {
+			return fence, true
+		}
+	}
+	return nil, false
+}
+
+// NextNonBlank returns the index of the first non-blank line after lines[i],
+// or the number of lines if there is none.
+func (b *Blocks) NextNonBlank(i int) int {
+	i++
+	for i < len(b.kinds) && b.kinds[i] == BlankLine {
+		i++
+	}
+	return i
+}
+
+type blockScanner struct {
+	blocks *Blocks
+
+	// lists holds the content columns of the open list items, innermost last.
+	lists     []int
+	paragraph bool
+	fence     *Fence
+	comment   bool
+	rawHTML   bool
+}
+
+func (s *blockScanner) scan(i int, line string) LineKind {
+	column, rest := Indentation(line)
+	blank := strings.TrimSpace(line) == ""
+
+	if s.fence != nil {
+		// A fence inside a list item ends with the item, closed or not.
+		if blank || column >= s.fence.column {
+			if column-s.fence.column < CodeIndent && IsClosingFence(rest, s.fence.Marker) {
+				s.fence.Close = i
+				s.fence = nil
+				return FenceLine
+			}
+			return CodeLine
+		}
+		s.fence = nil
+	}
+
+	if s.comment {
+		if strings.Contains(line, commentEnd) {
+			s.comment = false
+		}
+		return CommentBodyLine
+	}
+
+	if s.rawHTML {
+		s.rawHTML = !ContainsRawHTMLEnd(line)
+		return HTMLLine
+	}
+
+	if blank {
+		s.paragraph = false
+		return BlankLine
+	}
+
+	// Neither indented code nor anything that is not a block start can
+	// interrupt a paragraph, so the line continues it.
+	if s.paragraph && (column-s.container() >= CodeIndent || !StartsBlock(rest)) {
+		return TextLine
+	}
+
+	for len(s.lists) > 0 && column < s.container() {
+		s.lists = s.lists[:len(s.lists)-1]
+	}
+
+	container := s.container()
+	if column-container >= CodeIndent {
+		return CodeLine
+	}
+
+	// A list item may start with another block, e.g., "- ```go".
+	for {
+		width, ok := ListMarkerWidth(rest)
+		if !ok {
+			break
+		}
+		column += width
+		rest = rest[min(width, len(rest)):]
+		s.lists = append(s.lists, column)
+		if strings.TrimSpace(rest) == "" {
+			s.paragraph = false
+			return TextLine
+		}
+		container = column
+	}
+
+	if marker, info, ok := ParseOpeningFence(rest); ok {
+		s.fence = &Fence{
+			Open:   i,
+			Close:  -1,
+			Marker: marker,
+			Info:   info,
+			column: container,
+		}
+		s.blocks.fences = append(s.blocks.fences, s.fence)
+		s.paragraph = false
+		return FenceLine
+	}
+
+	if IsRawHTMLStart(rest) {
+		s.rawHTML = !ContainsRawHTMLEnd(rest)
+		s.paragraph = false
+		return HTMLLine
+	}
+
+	if strings.HasPrefix(rest, commentStart) {
+		s.comment = !strings.Contains(rest[len(commentStart):], commentEnd)
+		s.paragraph = false
+		return CommentLine
+	}
+
+	// Headings are a single line, whereas anything else starts or continues
+	// a paragraph.
+	s.paragraph = !strings.HasPrefix(rest, "#")
+	return TextLine
+}
+
+func (s *blockScanner) container() int {
+	if len(s.lists) == 0 {
+		return 0
+	}
+	return s.lists[len(s.lists)-1]
+}
+
+// Indentation returns the column at which the content of line starts, with
+// tabs expanded to multiples of TabWidth, and the content itself.
+func Indentation(line string) (int, string) {
+	column := 0
+	for i, r := range line {
+		switch r {
+		case ' ':
+			column++
+		case '\t':
+			column += TabWidth - column%TabWidth
+		default:
+			return column, line[i:]
+		}
+	}
+	return column, ""
+}
+
+// StartsBlock reports whether content, a line with its indentation removed,
+// starts a block that can interrupt a paragraph.
+func StartsBlock(content string) bool {
+	if _, _, ok := ParseOpeningFence(content); ok {
+		return true
+	}
+	if _, ok := ListMarkerWidth(content); ok {
+		return true
+	}
+	return IsRawHTMLStart(content) ||
+		strings.HasPrefix(content, commentStart) ||
+		strings.HasPrefix(content, "#") ||
+		strings.HasPrefix(content, ">")
+}
+
+// IsRawHTMLStart reports whether content, a line with its indentation
+// removed, opens a raw HTML block, e.g., "<pre>" or "<script src=x.js>".
+func IsRawHTMLStart(content string) bool {
+	lower := strings.ToLower(content)
+	for _, tag := range RawHTMLTags {
+		rest, ok := strings.CutPrefix(lower, "<"+tag)
+		if ok && (rest == "" || strings.ContainsAny(rest[:1], " \t>")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsRawHTMLEnd reports whether line closes a raw HTML block. The
+// closing tag need not match the tag that opened the block.
+func ContainsRawHTMLEnd(line string) bool {
+	lower := strings.ToLower(line)
+	for _, tag := range RawHTMLTags {
+		if strings.Contains(lower, "</"+tag+">") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseOpeningFence parses content, a line with its indentation removed, as
+// the opening fence of a fenced code block, e.g., "```go" or "~~~~ yaml".
+func ParseOpeningFence(content string) (string, string, bool) {
+	if !strings.HasPrefix(content, BacktickFence) && !strings.HasPrefix(content, TildeFence) {
+		return "", "", false
+	}
+
+	length := len(content) - len(strings.TrimLeft(content, content[:1]))
+	if length < MinFenceLength {
+		return "", "", false
+	}
+
+	marker := content[:length]
+	info := strings.TrimSpace(content[length:])
+	if marker[:1] == BacktickFence && strings.Contains(info, BacktickFence) {
+		return "", "", false
+	}
+	return marker, info, true
+}
+
+// IsClosingFence reports whether content, a line with its indentation
+// removed, closes a fenced code block opened with marker.
+func IsClosingFence(content string, marker string) bool {
+	trimmed := strings.TrimLeft(content, marker[:1])
+	length := len(content) - len(trimmed)
+	return length >= len(marker) && strings.TrimSpace(trimmed) == ""
+}
+
+// ListMarkerWidth returns the width of the list marker that content, a line
+// with its indentation removed, starts with, including the spaces after it,
+// e.g., 2 for "- item" and 4 for "10. item".
+func ListMarkerWidth(content string) (int, bool) {
+	n := 0
+	switch {
+	case strings.HasPrefix(content, "-"),
+		strings.HasPrefix(content, "*"),
+		strings.HasPrefix(content, "+"):
+		n = 1
+	default:
+		for n < len(content) && n < 9 && content[n] >= '0' && content[n] <= '9' {
+			n++
+		}
+		if n == 0 || n == len(content) || (content[n] != '.' && content[n] != ')') {
+			return 0, false
+		}
+		n++
+	}
+
+	rest := content[n:]
+	if rest == "" {
+		return n, true
+	}
+
+	spaces, after := Indentation(rest)
+	switch {
+	case spaces == 0:
+		return 0, false
+	case after == "" || spaces > CodeIndent:
+		// Content starting with indented code keeps a single space.
+		return n + 1, true
+	default:
+		return n + spaces, true
+	}
+}
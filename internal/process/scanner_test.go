@@ -0,0 +1,115 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+func TestScanBlocks(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		lines := []string{
+			"# Title",               // 0
+			"<!-- pluck() -->",      // 1
+			"````go",                // 2
+			"```",                   // 3
+			"````",                  // 4
+			"text <!-- pluck() -->", // 5
+			"    <!-- pluck() -->",  // 6
+			"",                      // 7
+			"    <!-- pluck() -->",  // 8
+			"",                      // 9
+			"- item",                // 10
+			"",                      // 11
+			"  <!-- pluck() -->",    // 12
+			"  ~~~yaml",             // 13
+			"  <!--",                // 14
+			"  ~~~",                 // 15
+			"<!--",                  // 16
+			"pluck()",               // 17
+			"-->",                   // 18
+			"```yaml",               // 19
+		}
+
+		// when
+		blocks := process.ScanBlocks(lines)
+
+		// then
+		want := []process.LineKind{
+			process.TextLine,
+			process.CommentLine,
+			process.FenceLine,
+			process.CodeLine,
+			process.FenceLine,
+			process.TextLine,
+			process.TextLine,
+			process.BlankLine,
+			process.CodeLine,
+			process.BlankLine,
+			process.TextLine,
+			process.BlankLine,
+			process.CommentLine,
+			process.FenceLine,
+			process.CodeLine,
+			process.FenceLine,
+			process.CommentLine,
+			process.CommentBodyLine,
+			process.CommentBodyLine,
+			process.FenceLine,
+		}
+		for i, kind := range want {
+			assert.Equal(t, kind, blocks.Kind(i), "line %d", i)
+		}
+
+		fences := blocks.Fences()
+		require.Len(t, fences, 3)
+		assert.Equal(t, process.Fence{Open: 2, Close: 4, Marker: "````", Info: "go"}, withoutColumn(fences[0]))
+		assert.Equal(t, process.Fence{Open: 13, Close: 15, Marker: "~~~", Info: "yaml"}, withoutColumn(fences[1]))
+		assert.Equal(t, -1, fences[2].Close)
+		assert.Equal(t, "yaml", fences[2].Lang())
+	})
+
+	t.Run("happy path - raw html", func(t *testing.T) {
+		// given
+		lines := []string{
+			"<pre>",                // 0
+			"<!-- pluck() -->",     // 1
+			"",                     // 2
+			"```go",                // 3
+			"</pre>",               // 4
+			"<!-- pluck() -->",     // 5
+			"Text",                 // 6
+			"<SCRIPT>x()</SCRIPT>", // 7
+			"<!-- pluck() -->",     // 8
+			"<presentation>",       // 9
+		}
+
+		// when
+		blocks := process.ScanBlocks(lines)
+
+		// then
+		want := []process.LineKind{
+			process.HTMLLine,
+			process.HTMLLine,
+			process.HTMLLine,
+			process.HTMLLine,
+			process.HTMLLine,
+			process.CommentLine,
+			process.TextLine,
+			process.HTMLLine,
+			process.CommentLine,
+			process.TextLine,
+		}
+		for i, kind := range want {
+			assert.Equal(t, kind, blocks.Kind(i), "line %d", i)
+		}
+		assert.Empty(t, blocks.Fences())
+	})
+}
+
+func withoutColumn(f *process.Fence) process.Fence {
+	return process.Fence{Open: f.Open, Close: f.Close, Marker: f.Marker, Info: f.Info}
+}
@@ -0,0 +1,392 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrSyntax = errors.New("syntax error")
+)
+
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenCommentStart
+	TokenCommentEnd
+	TokenIdent
+	TokenString
+	TokenInt
+	TokenLParen
+	TokenRParen
+	TokenComma
+	TokenEquals
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenEOF:
+		return "end of line"
+	case TokenCommentStart:
+		return "'" + commentStart + "'"
+	case TokenCommentEnd:
+		return "'" + commentEnd + "'"
+	case TokenIdent:
+		return "identifier"
+	case TokenString:
+		return "string"
+	case TokenInt:
+		return "integer"
+	case TokenLParen:
+		return "'('"
+	case TokenRParen:
+		return "')'"
+	case TokenComma:
+		return "','"
+	case TokenEquals:
+		return "'='"
+	default:
+		return "unknown token"
+	}
+}
+
+// IsValue reports whether tokens of kind k can be argument values.
+func (k TokenKind) IsValue() bool {
+	return k == TokenIdent || k == TokenString || k == TokenInt
+}
+
+// Token is a lexical token of a directive. Pos is the byte offset of the token
+// in the input, and Text is its source text, e.g., a string token's text
+// includes the quotes.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  int
+}
+
+// Tokenizer splits a directive into tokens. Whitespace between tokens is
+// skipped.
+type Tokenizer struct {
+	input string
+	pos   int
+}
+
+func NewTokenizer(input string) *Tokenizer {
+	return &Tokenizer{input: input}
+}
+
+// Next returns the next token, or a TokenEOF token once the input has been
+// consumed.
+func (t *Tokenizer) Next() (Token, error) {
+	t.skipSpace()
+
+	start := t.pos
+	rest := t.input[t.pos:]
+	switch {
+	case rest == "":
+		return Token{Kind: TokenEOF, Pos: start}, nil
+	case strings.HasPrefix(rest, commentStart):
+		return t.emit(TokenCommentStart, len(commentStart)), nil
+	case strings.HasPrefix(rest, commentEnd):
+		return t.emit(TokenCommentEnd, len(commentEnd)), nil
+	}
+
+	switch r, _ := utf8.DecodeRuneInString(rest); {
+	case r == '(':
+		return t.emit(TokenLParen, 1), nil
+	case r == ')':
+		return t.emit(TokenRParen, 1), nil
+	case r == ',':
+		return t.emit(TokenComma, 1), nil
+	case r == '=':
+		return t.emit(TokenEquals, 1), nil
+	case r == '"':
+		return t.lexString()
+	case r == '-' || unicode.IsDigit(r):
+		return t.lexInt()
+	case r == '_' || unicode.IsLetter(r):
+		return t.lexIdent(), nil
+	default:
+		return Token{}, fmt.Errorf(
+			"%w: unexpected character %q at offset %d",
+			ErrSyntax,
+			r,
+			start,
+		)
+	}
+}
+
+func (t *Tokenizer) emit(kind TokenKind, length int) Token {
+	token := Token{Kind: kind, Text: t.input[t.pos : t.pos+length], Pos: t.pos}
+	t.pos += length
+	return token
+}
+
+func (t *Tokenizer) skipSpace() {
+	for t.pos < len(t.input) {
+		r, size := utf8.DecodeRuneInString(t.input[t.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		t.pos += size
+	}
+}
+
+func (t *Tokenizer) lexString() (Token, error) {
+	// Skip the opening quote and find the closing one, stepping over escaped
+	// characters.
+	i := t.pos + 1
+	for i < len(t.input) {
+		switch t.input[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			return t.emit(TokenString, i+1-t.pos), nil
+		}
+		i++
+	}
+	return Token{}, fmt.Errorf(
+		"%w: unterminated string at offset %d",
+		ErrSyntax,
+		t.pos,
+	)
+}
+
+func (t *Tokenizer) lexInt() (Token, error) {
+	i := t.pos
+	if t.input[i] == '-' {
+		i++
+	}
+	digits := i
+	for i < len(t.input) && t.input[i] >= '0' && t.input[i] <= '9' {
+		i++
+	}
+	if i == digits {
+		return Token{}, fmt.Errorf(
+			"%w: expected digits after '-' at offset %d",
+			ErrSyntax,
+			t.pos,
+		)
+	}
+	return t.emit(TokenInt, i-t.pos), nil
+}
+
+func (t *Tokenizer) lexIdent() Token {
+	i := t.pos
+	for i < len(t.input) {
+		r, size := utf8.DecodeRuneInString(t.input[i:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		i += size
+	}
+	return t.emit(TokenIdent, i-t.pos)
+}
+
+// Arg is an argument of a directive. Name is empty for positional arguments.
+type Arg struct {
+	Name  string
+	Value Token
+}
+
+// String returns the value of a string argument with its quotes removed and
+// escape sequences interpreted.
+func (a Arg) String() (string, error) {
+	if a.Value.Kind != TokenString {
+		return "", a.typeError(TokenString)
+	}
+	value, err := strconv.Unquote(a.Value.Text)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid string %s: %w", ErrSyntax, a.Value.Text, err)
+	}
+	return value, nil
+}
+
+func (a Arg) Int() (int, error) {
+	if a.Value.Kind != TokenInt {
+		return 0, a.typeError(TokenInt)
+	}
+	value, err := strconv.Atoi(a.Value.Text)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer %s: %w", ErrSyntax, a.Value.Text, err)
+	}
+	return value, nil
+}
+
+// Bool returns the value of a boolean argument, which is written as the
+// identifier true or false.
+func (a Arg) Bool() (bool, error) {
+	if a.Value.Kind == TokenIdent {
+		switch a.Value.Text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf(
+		"%w: %s must be true or false, got %s",
+		ErrSyntax,
+		a.Name,
+		a.Value.Text,
+	)
+}
+
+func (a Arg) typeError(want TokenKind) error {
+	return fmt.Errorf(
+		"%w: %s must be a %s, got %s",
+		ErrSyntax,
+		a.Name,
+		want,
+		a.Value.Text,
+	)
+}
+
+// ParseCall parses a directive comment of the form
+//
+//	<!-- pluck(arg, ...) -->
+//
+// where every arg is either a value or name=value, starting at the beginning
+// of input. It returns the arguments and the length of the comment, so that
+// any text after the comment can be ignored.
+func ParseCall(input string) ([]Arg, int, error) {
+	p := &callParser{tokenizer: NewTokenizer(input)}
+	args, err := p.parse()
+	if err != nil {
+		return nil, 0, err
+	}
+	return args, p.tokenizer.pos, nil
+}
+
+type callParser struct {
+	tokenizer *Tokenizer
+	token     Token
+}
+
+func (p *callParser) parse() ([]Arg, error) {
+	err := p.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(TokenCommentStart)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	if name.Text != pluckName {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrSyntax, pluckName, name.Text)
+	}
+
+	_, err = p.expect(TokenLParen)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []Arg
+	for p.token.Kind != TokenRParen {
+		arg, argErr := p.parseArg()
+		if argErr != nil {
+			return nil, argErr
+		}
+		args = append(args, arg)
+
+		if p.token.Kind != TokenComma {
+			break
+		}
+		err = p.advance()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = p.expect(TokenRParen)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stop right after the closing "-->" rather than reading on, since the
+	// rest of the line may be arbitrary markdown.
+	if p.token.Kind != TokenCommentEnd {
+		return nil, p.unexpected(TokenCommentEnd)
+	}
+	return args, nil
+}
+
+func (p *callParser) parseArg() (Arg, error) {
+	first := p.token
+	if first.Kind != TokenIdent {
+		if !first.Kind.IsValue() {
+			return Arg{}, p.unexpected(TokenIdent)
+		}
+		return Arg{Value: first}, p.advance()
+	}
+
+	err := p.advance()
+	if err != nil {
+		return Arg{}, err
+	}
+	if p.token.Kind != TokenEquals {
+		// A bare identifier is a value, e.g., true or false.
+		return Arg{Value: first}, nil
+	}
+
+	err = p.advance()
+	if err != nil {
+		return Arg{}, err
+	}
+
+	value := p.token
+	if !value.Kind.IsValue() {
+		return Arg{}, fmt.Errorf(
+			"%w: expected a value for %s, got %s",
+			ErrSyntax,
+			first.Text,
+			describe(value),
+		)
+	}
+	return Arg{Name: first.Text, Value: value}, p.advance()
+}
+
+func (p *callParser) advance() error {
+	token, err := p.tokenizer.Next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *callParser) expect(kind TokenKind) (Token, error) {
+	token := p.token
+	if token.Kind != kind {
+		return Token{}, p.unexpected(kind)
+	}
+	return token, p.advance()
+}
+
+func (p *callParser) unexpected(want TokenKind) error {
+	return fmt.Errorf(
+		"%w: expected %s, got %s",
+		ErrSyntax,
+		want,
+		describe(p.token),
+	)
+}
+
+func describe(token Token) string {
+	if token.Kind == TokenEOF {
+		return token.Kind.String()
+	}
+	return token.Text
+}
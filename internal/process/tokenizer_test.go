@@ -0,0 +1,80 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+func TestParseCall(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		input := `<!-- pluck(name="a \"b\"", "c", -1, doc=true) --> trailing`
+
+		// when
+		args, length, err := process.ParseCall(input)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, len(`<!-- pluck(name="a \"b\"", "c", -1, doc=true) -->`), length)
+		require.Len(t, args, 4)
+
+		assert.Equal(t, "name", args[0].Name)
+		name, err := args[0].String()
+		require.NoError(t, err)
+		assert.Equal(t, `a "b"`, name)
+
+		assert.Empty(t, args[1].Name)
+		assert.Equal(t, process.TokenString, args[1].Value.Kind)
+
+		value, err := args[2].Int()
+		require.NoError(t, err)
+		assert.Equal(t, -1, value)
+
+		doc, err := args[3].Bool()
+		require.NoError(t, err)
+		assert.True(t, doc)
+	})
+
+	t.Run("happy path - no arguments", func(t *testing.T) {
+		// when
+		args, _, err := process.ParseCall(`<!--pluck()-->`)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, args)
+	})
+
+	t.Run("error - wrong type", func(t *testing.T) {
+		// given
+		args, _, err := process.ParseCall(`<!-- pluck(lines=10) -->`)
+		require.NoError(t, err)
+
+		// when
+		_, err = args[0].String()
+
+		// then
+		require.ErrorIs(t, err, process.ErrSyntax)
+	})
+
+	syntaxErrors := []struct {
+		name  string
+		input string
+	}{
+		{name: "missing comma", input: `<!-- pluck(name="a" kind="b") -->`},
+		{name: "missing value", input: `<!-- pluck(name=) -->`},
+		{name: "single quotes", input: `<!-- pluck(name='a') -->`},
+		{name: "unterminated string", input: `<!-- pluck(name="a) -->`},
+		{name: "missing closing paren", input: `<!-- pluck(name="a" -->`},
+		{name: "missing comment end", input: `<!-- pluck(name="a")`},
+		{name: "not pluck", input: `<!-- plonk(name="a") -->`},
+	}
+	for _, tt := range syntaxErrors {
+		t.Run("error - "+tt.name, func(t *testing.T) {
+			_, _, err := process.ParseCall(tt.input)
+			require.ErrorIs(t, err, process.ErrSyntax)
+		})
+	}
+}
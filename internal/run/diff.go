@@ -0,0 +1,23 @@
+package run
+
+import (
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	DiffContextLines = 3
+	DiffFromPrefix   = "a/"
+	DiffToPrefix     = "b/"
+)
+
+// UnifiedDiff returns a unified diff of the original and processed contents
+// of a markdown file in the same format as `git diff`.
+func UnifiedDiff(file string, original []byte, processed []byte) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(original)),
+		B:        difflib.SplitLines(string(processed)),
+		FromFile: DiffFromPrefix + file,
+		ToFile:   DiffToPrefix + file,
+		Context:  DiffContextLines,
+	})
+}
@@ -0,0 +1,212 @@
+package run
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	GitIgnoreFile     = ".gitignore"
+	PluckMDIgnoreFile = ".pluckmdignore"
+	AnyDirPrefix      = "**/"
+	NegatePrefix      = "!"
+	CommentPrefix     = "#"
+	EscapePrefix      = `\`
+	PathSeparator     = "/"
+)
+
+var (
+	ErrInvalidPattern = errors.New("invalid pattern")
+)
+
+// Pattern is a single gitignore-style pattern. Patterns that do not contain
+// a slash (other than a trailing one) match at any depth, e.g., "testdata/"
+// matches both "testdata" and "internal/process/testdata". Patterns that do
+// contain a slash are anchored to the directory of the file that declared
+// them, e.g., "internal/pluck" only matches that exact directory. Patterns
+// support doublestar globs such as "docs/**/*.md".
+type Pattern struct {
+	glob    string
+	negate  bool
+	dirOnly bool
+}
+
+// NewPattern parses a gitignore-style pattern declared in the directory base,
+// given relative to the root being walked ("" for the root itself).
+func NewPattern(pattern string, base string) (*Pattern, error) {
+	p := &Pattern{}
+	if strings.HasPrefix(pattern, NegatePrefix) {
+		p.negate = true
+		pattern = strings.TrimPrefix(pattern, NegatePrefix)
+	}
+
+	// A leading backslash escapes a literal "#" or "!"
+	if strings.HasPrefix(pattern, EscapePrefix+CommentPrefix) ||
+		strings.HasPrefix(pattern, EscapePrefix+NegatePrefix) {
+		pattern = strings.TrimPrefix(pattern, EscapePrefix)
+	}
+	pattern = strings.TrimPrefix(pattern, "./")
+	if strings.HasSuffix(pattern, PathSeparator) {
+		p.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, PathSeparator)
+	}
+
+	if !strings.Contains(pattern, PathSeparator) {
+		pattern = AnyDirPrefix + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, PathSeparator)
+	if base != "" {
+		pattern = base + PathSeparator + pattern
+	}
+
+	if pattern == "" || !doublestar.ValidatePattern(pattern) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidPattern, pattern)
+	}
+	p.glob = pattern
+	return p, nil
+}
+
+// Match reports whether the slash-separated path, relative to the root
+// being walked, matches the pattern.
+func (p *Pattern) Match(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return doublestar.MatchUnvalidated(p.glob, relPath)
+}
+
+// Matcher matches paths against an ordered list of patterns. As with
+// .gitignore files, the last matching pattern wins, so a negated pattern can
+// re-include a path excluded by an earlier pattern.
+type Matcher struct {
+	patterns []*Pattern
+}
+
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	err := m.Add(patterns, "")
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Matcher) Add(patterns []string, base string) error {
+	for _, pattern := range patterns {
+		p, err := NewPattern(pattern, base)
+		if err != nil {
+			return err
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return nil
+}
+
+// AddFile adds the patterns from an ignore file (e.g., .gitignore) located in
+// the directory base. It is not an error for the file to not exist.
+func (m *Matcher) AddFile(file string, base string) error {
+	data, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	return m.Add(ReadIgnorePatterns(data), base)
+}
+
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	matched := false
+	for _, p := range m.patterns {
+		if p.Match(relPath, isDir) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// ReadIgnorePatterns returns the patterns in an ignore file, skipping blank
+// lines and comments.
+func ReadIgnorePatterns(data []byte) []string {
+	patterns := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, CommentPrefix) {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// AddIgnoreFiles adds the patterns from the ignore files in the directory dir
+// to the matcher, anchored relative to root. The .gitignore file is only read
+// if gitignore is set.
+func AddIgnoreFiles(m *Matcher, dir string, root string, gitignore bool) error {
+	base, err := RelPath(root, dir)
+	if err != nil {
+		return err
+	}
+	if base == "." {
+		base = ""
+	}
+
+	if gitignore {
+		err = m.AddFile(filepath.Join(dir, GitIgnoreFile), base)
+		if err != nil {
+			return err
+		}
+	}
+	return m.AddFile(filepath.Join(dir, PluckMDIgnoreFile), base)
+}
+
+// FindRepoRoot returns the nearest directory at or above the absolute
+// directory dir that contains a .git entry, or dir itself if there is none.
+func FindRepoRoot(dir string) string {
+	for current := dir; ; {
+		_, err := os.Stat(filepath.Join(current, GitDirName))
+		if err == nil {
+			return current
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// ParentDirs returns the directories from root down to, but excluding, dir,
+// which must be root or beneath it.
+func ParentDirs(root string, dir string) []string {
+	parents := []string{}
+	for current := dir; current != root; {
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		parents = append([]string{parent}, parents...)
+		current = parent
+	}
+	return parents
+}
+
+// RelPath returns the slash-separated path of target relative to root.
+func RelPath(root string, target string) (string, error) {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return "", err
+	}
+	return path.Clean(filepath.ToSlash(rel)), nil
+}
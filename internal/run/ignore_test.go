@@ -0,0 +1,190 @@
+package run_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+func writeTestTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestListMarkdownFiles(t *testing.T) {
+	tree := map[string]string{
+		".gitignore":                  "node_modules/\n",
+		".git/README.md":              "",
+		"README.md":                   "",
+		"docs/.pluckmdignore":         "# drafts are not ready yet\ndrafts/\n",
+		"docs/guide.md":               "",
+		"docs/guide.mdx":              "",
+		"docs/notes.markdown":         "",
+		"docs/drafts/wip.md":          "",
+		"internal/pluck/README.md":    "",
+		"internal/process/README.md":  "",
+		"internal/process/testdata/a": "",
+		"node_modules/pkg/README.md":  "",
+		"testdata/README.md":          "",
+	}
+
+	tests := []struct {
+		name string
+		opts run.ListOptions
+		want []string
+	}{
+		{
+			name: "default options",
+			opts: run.ListOptions{Gitignore: true},
+			want: []string{
+				"README.md",
+				"docs/guide.md",
+				"internal/pluck/README.md",
+				"internal/process/README.md",
+				"testdata/README.md",
+			},
+		},
+		{
+			name: "ignore gitignore",
+			opts: run.ListOptions{Gitignore: false},
+			want: []string{
+				"README.md",
+				"docs/guide.md",
+				"internal/pluck/README.md",
+				"internal/process/README.md",
+				"node_modules/pkg/README.md",
+				"testdata/README.md",
+			},
+		},
+		{
+			name: "exclude nested directory",
+			opts: run.ListOptions{
+				Exclude:   []string{"internal/pluck"},
+				Gitignore: true,
+			},
+			want: []string{
+				"README.md",
+				"docs/guide.md",
+				"internal/process/README.md",
+				"testdata/README.md",
+			},
+		},
+		{
+			name: "exclude directory at any depth",
+			opts: run.ListOptions{
+				Exclude:   []string{"./pluck/", "testdata/"},
+				Gitignore: true,
+			},
+			want: []string{
+				"README.md",
+				"docs/guide.md",
+				"internal/process/README.md",
+			},
+		},
+		{
+			name: "exclude glob",
+			opts: run.ListOptions{
+				Exclude:   []string{"internal/**/*.md"},
+				Gitignore: true,
+			},
+			want: []string{
+				"README.md",
+				"docs/guide.md",
+				"testdata/README.md",
+			},
+		},
+		{
+			name: "include other extensions",
+			opts: run.ListOptions{
+				Include:   []string{"docs/*.md", "*.mdx", "*.markdown"},
+				Gitignore: true,
+			},
+			want: []string{
+				"docs/guide.md",
+				"docs/guide.mdx",
+				"docs/notes.markdown",
+			},
+		},
+	}
+
+	dir := writeTestTree(t, tree)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			got, err := run.ListMarkdownFiles(dir, tt.opts)
+
+			// then
+			require.NoError(t, err)
+			want := make([]string, 0, len(tt.want))
+			for _, file := range tt.want {
+				want = append(want, filepath.Join(dir, filepath.FromSlash(file)))
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("happy path - subdirectory of repo", func(t *testing.T) {
+		// given
+		dir := writeTestTree(t, map[string]string{
+			".gitignore":                      "node_modules/\n",
+			".git/HEAD":                       "",
+			".pluckmdignore":                  "docs/drafts/\n",
+			"docs/guide.md":                   "",
+			"docs/drafts/wip.md":              "",
+			"docs/internal/notes.md":          "",
+			"docs/node_modules/pkg/README.md": "",
+		})
+		opts := run.ListOptions{
+			Exclude:   []string{"docs/internal"},
+			Gitignore: true,
+		}
+
+		// when
+		got, err := run.ListMarkdownFiles(filepath.Join(dir, "docs"), opts)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "docs", "guide.md")}, got)
+	})
+
+	t.Run("error - invalid pattern", func(t *testing.T) {
+		// when
+		_, err := run.ListMarkdownFiles(dir, run.ListOptions{Exclude: []string{"[a-"}})
+
+		// then
+		require.ErrorIs(t, err, run.ErrInvalidPattern)
+	})
+}
+
+func TestMatcher_Match(t *testing.T) {
+	t.Run("negated pattern re-includes path", func(t *testing.T) {
+		// given
+		matcher, err := run.NewMatcher([]string{"*.md", "!keep.md"})
+		require.NoError(t, err)
+
+		// then
+		assert.True(t, matcher.Match("docs/drop.md", false))
+		assert.False(t, matcher.Match("docs/keep.md", false))
+	})
+
+	t.Run("directory only pattern", func(t *testing.T) {
+		// given
+		matcher, err := run.NewMatcher([]string{"build/"})
+		require.NoError(t, err)
+
+		// then
+		assert.True(t, matcher.Match("a/build", true))
+		assert.False(t, matcher.Match("a/build", false))
+	})
+}
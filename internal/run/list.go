@@ -0,0 +1,144 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+const (
+	ListTable = "table"
+	ListJSON  = "json"
+
+	// tablePadding is the number of spaces between table columns.
+	tablePadding = 2
+)
+
+var (
+	ErrUnknownListFormat = errors.New("unknown list format")
+)
+
+// DirectiveEntry is the listing of a single directive.
+type DirectiveEntry struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Lang   string `json:"lang"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+func NewDirectiveEntry(occurrence *process.Occurrence) *DirectiveEntry {
+	directive := occurrence.Directive
+	return &DirectiveEntry{
+		File:   occurrence.File,
+		Line:   occurrence.Line,
+		Lang:   string(directive.Lang()),
+		Kind:   string(directive.Kind()),
+		Name:   directive.Name(),
+		Source: directive.Source(),
+		Start:  directive.Start(),
+		End:    directive.End(),
+	}
+}
+
+// ListDirectives parses the directives in every markdown file without
+// fetching anything. Files that cannot be read and directives that cannot be
+// parsed are skipped and returned together as process.DiagnosticErrors.
+func ListDirectives(files []string) ([]*process.Occurrence, error) {
+	var occurrences []*process.Occurrence
+	var diagnostics process.DiagnosticErrors
+	for _, file := range files {
+		md, err := os.ReadFile(file)
+		if err != nil {
+			diagnostics = append(diagnostics, process.ToDiagnosticErrors(
+				file,
+				fmt.Errorf("%w: reading file: %w", ErrRunner, err),
+			)...)
+			continue
+		}
+
+		fileOccurrences, err := process.FindDirectives(file, md)
+		if err != nil {
+			diagnostics = append(diagnostics, process.ToDiagnosticErrors(file, err)...)
+		}
+		occurrences = append(occurrences, fileOccurrences...)
+	}
+	return occurrences, DiagnosticsError(diagnostics)
+}
+
+// WriteDirectives writes a listing of the directives to out, either as an
+// aligned table or as a JSON array.
+func WriteDirectives(
+	out io.Writer,
+	format string,
+	occurrences []*process.Occurrence,
+) error {
+	entries := make([]*DirectiveEntry, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		entries = append(entries, NewDirectiveEntry(occurrence))
+	}
+
+	var err error
+	switch format {
+	case ListTable:
+		err = writeDirectiveTable(out, entries)
+	case ListJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	default:
+		return fmt.Errorf("%w: %w: %s", ErrRunner, ErrUnknownListFormat, format)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: writing directives: %w", ErrRunner, err)
+	}
+	return nil
+}
+
+func writeDirectiveTable(out io.Writer, entries []*DirectiveEntry) error {
+	w := tabwriter.NewWriter(out, 0, 0, tablePadding, ' ', 0)
+	fmt.Fprintln(w, "FILE\tLINE\tLANG\tKIND\tNAME\tSOURCE\tRANGE")
+	for _, entry := range entries {
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%s\t%s\t%s\t%s\t%d,%d\n",
+			entry.File,
+			entry.Line,
+			entry.Lang,
+			entry.Kind,
+			entry.Name,
+			entry.Source,
+			entry.Start,
+			entry.End,
+		)
+	}
+	return w.Flush()
+}
+
+// LintFiles lints the directives in every markdown file without fetching
+// anything. All problems are returned together as process.DiagnosticErrors.
+func LintFiles(files []string) error {
+	var diagnostics process.DiagnosticErrors
+	for _, file := range files {
+		md, err := os.ReadFile(file)
+		if err != nil {
+			err = fmt.Errorf("%w: reading file: %w", ErrRunner, err)
+		} else {
+			err = process.Lint(file, md)
+		}
+
+		if err != nil {
+			diagnostics = append(diagnostics, process.ToDiagnosticErrors(file, err)...)
+		}
+	}
+	return DiagnosticsError(diagnostics)
+}
@@ -0,0 +1,152 @@
+package run_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/process"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+func TestListDirectives(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		dir := writeTestDir(t, unprocessedMD, "a.md", "b.md")
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+
+		// when
+		got, err := run.ListDirectives(files)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, files[0], got[0].File)
+		assert.Equal(t, files[1], got[1].File)
+		assert.Equal(t, "enclave.args", got[0].Directive.Name())
+	})
+
+	t.Run("error - missing file", func(t *testing.T) {
+		// given
+		dir := writeTestDir(t, unprocessedMD)
+		files := []string{
+			filepath.Join(dir, "missing.md"),
+			filepath.Join(dir, markdownFile),
+		}
+
+		// when
+		got, err := run.ListDirectives(files)
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 1)
+		require.ErrorIs(t, diagnostics[0], os.ErrNotExist)
+		require.Len(t, got, 1)
+	})
+}
+
+func TestWriteDirectives(t *testing.T) {
+	dir := writeTestDir(t, unprocessedMD)
+	file := filepath.Join(dir, markdownFile)
+	occurrences, err := run.ListDirectives([]string{file})
+	require.NoError(t, err)
+
+	t.Run("happy path - table", func(t *testing.T) {
+		// given
+		var out bytes.Buffer
+
+		// when
+		err := run.WriteDirectives(&out, run.ListTable, occurrences)
+
+		// then
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(
+			t,
+			[]string{"FILE", "LINE", "LANG", "KIND", "NAME", "SOURCE", "RANGE"},
+			strings.Fields(lines[0]),
+		)
+		assert.Equal(
+			t,
+			[]string{file, "3", "yaml", "node", "enclave.args", "./enclave-sev.yaml", "0,0"},
+			strings.Fields(lines[1]),
+		)
+	})
+
+	t.Run("happy path - json", func(t *testing.T) {
+		// given
+		var out bytes.Buffer
+
+		// when
+		err := run.WriteDirectives(&out, run.ListJSON, occurrences)
+
+		// then
+		require.NoError(t, err)
+		var got []*run.DirectiveEntry
+		require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+		want := []*run.DirectiveEntry{{
+			File:   file,
+			Line:   3,
+			Lang:   "yaml",
+			Kind:   "node",
+			Name:   "enclave.args",
+			Source: "./enclave-sev.yaml",
+		}}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("error - unknown format", func(t *testing.T) {
+		// given
+		var out bytes.Buffer
+
+		// when
+		err := run.WriteDirectives(&out, "xml", occurrences)
+
+		// then
+		require.ErrorIs(t, err, run.ErrUnknownListFormat)
+	})
+}
+
+func TestLintFiles(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		dir := writeTestDir(t, unprocessedMD, "a.md", "b.md")
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+
+		// when
+		err = run.LintFiles(files)
+
+		// then
+		require.NoError(t, err)
+	})
+
+	t.Run("error - malformed directive", func(t *testing.T) {
+		// given
+		broken := []byte(strings.Replace(string(unprocessedMD), `"`, `'`, 2))
+		dir := writeTestDir(t, unprocessedMD, "a.md")
+		brokenFile := filepath.Join(dir, "b.md")
+		require.NoError(t, os.WriteFile(brokenFile, broken, 0600))
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+
+		// when
+		err = run.LintFiles(files)
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 1)
+		require.ErrorIs(t, diagnostics[0], process.ErrMalformedDirective)
+		assert.Equal(t, brokenFile, diagnostics[0].File)
+		assert.Equal(t, 3, diagnostics[0].Line)
+	})
+}
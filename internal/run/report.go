@@ -0,0 +1,91 @@
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tahardi/pluckmd/internal/process"
+)
+
+const (
+	ReportJSON = "json"
+)
+
+var (
+	ErrUnknownReportFormat = errors.New("unknown report format")
+)
+
+// Report describes every markdown file and directive seen during a run.
+type Report struct {
+	Files []*FileReport `json:"files"`
+}
+
+// FileReport describes a single markdown file. Changed is set if processing
+// changed the file (or, when checking, would have). Error is set if the file
+// could not be read or written; directive failures are reported in
+// Directives instead.
+type FileReport struct {
+	File       string            `json:"file"`
+	Changed    bool              `json:"changed"`
+	Error      string            `json:"error,omitempty"`
+	Directives []*process.Result `json:"directives"`
+}
+
+func NewReport(results []*FileResult) *Report {
+	files := make([]*FileReport, 0, len(results))
+	for _, result := range results {
+		directives := result.Results
+		if directives == nil {
+			directives = []*process.Result{}
+		}
+
+		file := &FileReport{
+			File:       result.File,
+			Changed:    result.Processed != nil && !bytes.Equal(result.Original, result.Processed),
+			Directives: directives,
+		}
+		if result.Err != nil && !hasDirectiveError(directives) {
+			file.Error = result.Err.Error()
+		}
+		files = append(files, file)
+	}
+	return &Report{Files: files}
+}
+
+// ValidateReportFormat returns ErrUnknownReportFormat if format is not a
+// supported report format.
+func ValidateReportFormat(format string) error {
+	if format != ReportJSON {
+		return fmt.Errorf("%w: %w: %s", ErrRunner, ErrUnknownReportFormat, format)
+	}
+	return nil
+}
+
+// WriteReport writes a report on results to out in the given format.
+func WriteReport(out io.Writer, format string, results []*FileResult) error {
+	err := ValidateReportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(NewReport(results))
+	if err != nil {
+		return fmt.Errorf("%w: writing report: %w", ErrRunner, err)
+	}
+	return nil
+}
+
+func hasDirectiveError(results []*process.Result) bool {
+	for _, result := range results {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/config"
+	"github.com/tahardi/pluckmd/internal/fetch"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+type testReport struct {
+	Files []struct {
+		File       string `json:"file"`
+		Changed    bool   `json:"changed"`
+		Error      string `json:"error"`
+		Directives []struct {
+			File      string `json:"file"`
+			Line      int    `json:"line"`
+			Directive struct {
+				Lang   string `json:"lang"`
+				Kind   string `json:"kind"`
+				Name   string `json:"name"`
+				Source string `json:"source"`
+			} `json:"directive"`
+			Fetcher string `json:"fetcher"`
+			Cached  bool   `json:"cached"`
+			Changed bool   `json:"changed"`
+			Error   string `json:"error"`
+		} `json:"directives"`
+	} `json:"files"`
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		file := filepath.Join(dir, markdownFile)
+		results, err := runner.Run(ctx, []string{file})
+		require.NoError(t, err)
+
+		// when
+		var out bytes.Buffer
+		err = run.WriteReport(&out, run.ReportJSON, results)
+
+		// then
+		require.NoError(t, err)
+		var report testReport
+		require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+		require.Len(t, report.Files, 1)
+		assert.Equal(t, file, report.Files[0].File)
+		assert.True(t, report.Files[0].Changed)
+		assert.Empty(t, report.Files[0].Error)
+
+		require.Len(t, report.Files[0].Directives, 1)
+		directive := report.Files[0].Directives[0]
+		assert.Equal(t, file, directive.File)
+		assert.Equal(t, 3, directive.Line)
+		assert.Equal(t, "yaml", directive.Directive.Lang)
+		assert.Equal(t, "node", directive.Directive.Kind)
+		assert.Equal(t, "enclave.args", directive.Directive.Name)
+		assert.Equal(t, "./"+enclaveSEVYAMLFile, directive.Directive.Source)
+		assert.Equal(t, fetch.LocalFetcherName, directive.Fetcher)
+		assert.False(t, directive.Cached)
+		assert.True(t, directive.Changed)
+		assert.Empty(t, directive.Error)
+	})
+
+	t.Run("happy path - cached and unchanged", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, processedMD, "a.md", "b.md")
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+		results, err := runner.Check(ctx, files, nil)
+		require.NoError(t, err)
+
+		// when
+		var out bytes.Buffer
+		err = run.WriteReport(&out, run.ReportJSON, results)
+
+		// then
+		require.NoError(t, err)
+		var report testReport
+		require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+		require.Len(t, report.Files, 2)
+		for _, file := range report.Files {
+			assert.False(t, file.Changed)
+			require.Len(t, file.Directives, 1)
+			assert.False(t, file.Directives[0].Changed)
+		}
+		assert.False(t, report.Files[0].Directives[0].Cached)
+		assert.True(t, report.Files[1].Directives[0].Cached)
+	})
+
+	t.Run("error - failing directive", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		require.NoError(t, os.Remove(filepath.Join(dir, enclaveSEVYAMLFile)))
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		results, err := runner.Run(ctx, []string{filepath.Join(dir, markdownFile)})
+		require.Error(t, err)
+
+		// when
+		var out bytes.Buffer
+		err = run.WriteReport(&out, run.ReportJSON, results)
+
+		// then
+		require.NoError(t, err)
+		var report testReport
+		require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+		require.Len(t, report.Files, 1)
+		assert.False(t, report.Files[0].Changed)
+		assert.Empty(t, report.Files[0].Error)
+		require.Len(t, report.Files[0].Directives, 1)
+		assert.Contains(t, report.Files[0].Directives[0].Error, "reading file")
+	})
+
+	t.Run("error - unknown format", func(t *testing.T) {
+		// given
+		var out bytes.Buffer
+
+		// when
+		err := run.WriteReport(&out, "xml", nil)
+
+		// then
+		require.ErrorIs(t, err, run.ErrUnknownReportFormat)
+	})
+}
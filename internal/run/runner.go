@@ -1,14 +1,19 @@
 package run
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/tahardi/pluckmd/internal/cache"
+	"github.com/tahardi/pluckmd/internal/config"
 	"github.com/tahardi/pluckmd/internal/fetch"
 	"github.com/tahardi/pluckmd/internal/pluck"
 	"github.com/tahardi/pluckmd/internal/process"
@@ -16,109 +21,508 @@ import (
 
 const (
 	DefaultPermissions = 0644
-	MarkdownExt        = ".md"
+	MarkdownPattern    = "*.md"
+	GitDirName         = ".git"
+	StdinName          = "<stdin>"
 )
 
 var (
-	ErrRunner = errors.New("runner")
+	ErrRunner      = errors.New("runner")
+	ErrStaleFiles  = errors.New("stale files")
+	ErrInvalidJobs = errors.New("invalid number of jobs")
 )
 
+// ListOptions controls which files ListMarkdownFiles returns. Include and
+// Exclude are gitignore-style patterns matched against paths relative to the
+// root of the repo containing the directory being listed (see FindRepoRoot).
+// If Include is empty, only ".md" files are included. Patterns in
+// .pluckmdignore files are always excluded, and if Gitignore is set, patterns
+// in .gitignore files are excluded as well.
+type ListOptions struct {
+	Include   []string
+	Exclude   []string
+	Gitignore bool
+}
+
 type Runner struct {
 	processor *process.Processor
+	jobs      int
 }
 
-func NewRunner() (*Runner, error) {
+// FileResult holds the original and processed contents of a markdown file,
+// and the results of the directives in it.
+type FileResult struct {
+	File      string
+	Original  []byte
+	Processed []byte
+	Results   []*process.Result
+	Err       error
+}
+
+// NewRunner builds a Runner from cfg. Snippets and sources are cached for the
+// current run only, except for remote sources, which are persisted if a cache
+// is configured. See NewFetchersWithCacher.
+func NewRunner(cfg *config.Config) (*Runner, error) {
 	cacher, err := cache.NewRAMCacher()
 	if err != nil {
 		return nil, err
 	}
 
-	ghFetcher, err := fetch.NewGitHubFetcher()
+	remoteCacher, err := NewCacher(cfg.Cache.Dir, cfg.Cache.TTL)
 	if err != nil {
 		return nil, err
 	}
 
-	lFetcher, err := fetch.NewLocalFetcher()
+	fetchers, err := NewFetchersWithCacher(
+		cfg.Fetchers,
+		cfg.Aliases,
+		cfg.BaseDir,
+		remoteCacher,
+	)
 	if err != nil {
 		return nil, err
 	}
-	fetchers := []fetch.Fetcher{ghFetcher, lFetcher}
 
-	goPlucker, err := pluck.NewGoPlucker()
+	pluckers, err := NewPluckers(cfg.Langs, cfg.GoPlucker)
 	if err != nil {
 		return nil, err
 	}
-	yamlPlucker, err := pluck.NewYAMLPlucker()
+
+	return NewRunnerWithProcessor(
+		process.NewProcessorWithKeepGoing(cacher, fetchers, pluckers, cfg.KeepGoing),
+		cfg.Jobs,
+	)
+}
+
+// NewFetchers returns the named fetchers in order, each wrapped so that it
+// expands source aliases before fetching. Local sources are resolved relative
+// to baseDir, or the working directory if baseDir is empty.
+func NewFetchers(
+	names []string,
+	aliases map[string]string,
+	baseDir string,
+) ([]fetch.Fetcher, error) {
+	remoteCacher, err := cache.NewRAMCacher()
+	if err != nil {
+		return nil, err
+	}
+	return NewFetchersWithCacher(names, aliases, baseDir, remoteCacher)
+}
+
+// NewFetchersWithCacher is like NewFetchers, but the sources fetched from
+// GitHub are cached in remoteCacher, keyed by their alias-expanded URL. Local
+// sources are never cached there, since they may change at any time, e.g.,
+// while editing docs and code together.
+func NewFetchersWithCacher(
+	names []string,
+	aliases map[string]string,
+	baseDir string,
+	remoteCacher cache.Cacher,
+) ([]fetch.Fetcher, error) {
+	fetchers := make([]fetch.Fetcher, 0, len(names))
+	for _, name := range names {
+		var fetcher fetch.Fetcher
+		var err error
+		switch name {
+		case config.GitHubFetcher:
+			fetcher, err = NewGitHubFetcher(remoteCacher)
+		case config.LocalFetcher:
+			fetcher, err = NewLocalFetcher(baseDir)
+		default:
+			err = fmt.Errorf("%w: %w: %s", ErrRunner, config.ErrUnknownFetcher, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		aliasFetcher, err := fetch.NewAliasFetcher(aliases, fetcher)
+		if err != nil {
+			return nil, err
+		}
+		fetchers = append(fetchers, aliasFetcher)
+	}
+	return fetchers, nil
+}
+
+func NewGitHubFetcher(cacher cache.Cacher) (*fetch.CachingFetcher, error) {
+	fetcher, err := fetch.NewGitHubFetcher()
 	if err != nil {
 		return nil, err
 	}
-	pluckers := map[pluck.Lang]pluck.Plucker{
-		pluck.Go:   goPlucker,
-		pluck.YAML: yamlPlucker,
+	return fetch.NewCachingFetcher(cacher, fetcher)
+}
+
+func NewLocalFetcher(baseDir string) (*fetch.LocalFletcher, error) {
+	if baseDir == "" {
+		return fetch.NewLocalFetcher()
+	}
+	return fetch.NewLocalFetcherWithBaseDir(baseDir)
+}
+
+// NewPluckers returns a plucker for each lang. For Go, goPlucker chooses
+// between the native and the exec-based plucker.
+func NewPluckers(
+	langs []pluck.Lang,
+	goPlucker string,
+) (map[pluck.Lang]pluck.Plucker, error) {
+	pluckers := make(map[pluck.Lang]pluck.Plucker, len(langs))
+	for _, lang := range langs {
+		var plucker pluck.Plucker
+		var err error
+		switch lang {
+		case pluck.Go:
+			plucker, err = NewGoPlucker(goPlucker)
+		case pluck.YAML:
+			plucker, err = pluck.NewYAMLPlucker()
+		default:
+			err = fmt.Errorf("%w: %w: %s", ErrRunner, config.ErrUnknownLang, lang)
+		}
+		if err != nil {
+			return nil, err
+		}
+		pluckers[lang] = plucker
+	}
+	return pluckers, nil
+}
+
+func NewGoPlucker(goPlucker string) (pluck.Plucker, error) {
+	switch goPlucker {
+	case config.NativeGoPlucker:
+		return pluck.NewGoPlucker()
+	case config.ExecGoPlucker:
+		return pluck.NewExecGoPlucker()
+	default:
+		return nil, fmt.Errorf("%w: %w: %s", ErrRunner, config.ErrUnknownGoPlucker, goPlucker)
+	}
+}
+
+// NewCacher returns a DiskCacher if either a cache directory or TTL is given,
+// filling in the default for whichever is missing. Otherwise, it returns a
+// RAMCacher that only lives as long as the current run.
+func NewCacher(cacheDir string, cacheTTL time.Duration) (cache.Cacher, error) {
+	if cacheDir == "" && cacheTTL == 0 {
+		return cache.NewRAMCacher()
+	}
+
+	if cacheDir == "" {
+		defaultDir, err := cache.DefaultDiskCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = defaultDir
 	}
 
-	return NewRunnerWithProcessor(process.NewProcessor(cacher, fetchers, pluckers))
+	if cacheTTL == 0 {
+		cacheTTL = cache.DefaultDiskCacheTTL
+	}
+	return cache.NewDiskCacherWithDirAndTTL(cacheDir, cacheTTL)
 }
 
-func NewRunnerWithProcessor(processor *process.Processor) (*Runner, error) {
-	return &Runner{processor: processor}, nil
+func NewRunnerWithProcessor(
+	processor *process.Processor,
+	jobs int,
+) (*Runner, error) {
+	if jobs < 1 {
+		return nil, fmt.Errorf("%w: %w: %d", ErrRunner, ErrInvalidJobs, jobs)
+	}
+	return &Runner{processor: processor, jobs: jobs}, nil
 }
 
+// Run processes the markdown files and writes the results back to the files
+// that changed. If the processor keeps going after errors, files are written
+// with their failing code blocks left untouched, and every failure is
+// returned together as process.DiagnosticErrors once all files have been
+// processed. The results of every processed file are returned even if there
+// was an error.
 func (r *Runner) Run(
 	ctx context.Context,
-	dir string,
-	ignoreDirs []string,
-) error {
-	files, err := ListMarkdownFiles(dir, ignoreDirs)
-	if err != nil {
-		return fmt.Errorf("%w: listing markdown files: %w", ErrRunner, err)
+	files []string,
+) ([]*FileResult, error) {
+	// Results are handled in file order, so a failure leaves the files before
+	// it written and the files after it untouched, just like a sequential run.
+	results := r.ProcessFiles(ctx, files)
+	var diagnostics process.DiagnosticErrors
+	for _, result := range results {
+		if result.Err != nil {
+			err := r.keepGoing(&diagnostics, result.File, result.Err)
+			if err != nil {
+				return results, err
+			}
+		}
+		// Skip unchanged files so that their mtimes don't change.
+		if result.Processed == nil || bytes.Equal(result.Processed, result.Original) {
+			continue
+		}
+
+		writeErr := WriteFile(result.File, result.Processed)
+		if writeErr != nil {
+			result.Err = fmt.Errorf("%w: writing file: %w", ErrRunner, writeErr)
+			err := r.keepGoing(&diagnostics, result.File, result.Err)
+			if err != nil {
+				return results, err
+			}
+		}
 	}
+	return results, DiagnosticsError(diagnostics)
+}
 
-	for _, file := range files {
-		bytes, readErr := os.ReadFile(file)
-		if readErr != nil {
-			return fmt.Errorf("%w: reading file: %w", ErrRunner, readErr)
+// Check processes the markdown files like Run, but instead of writing the
+// results it prints a unified diff to out for every file that is out of date.
+// It returns ErrStaleFiles if any file would have been changed. If out is
+// nil, no diffs are printed.
+func (r *Runner) Check(
+	ctx context.Context,
+	files []string,
+	out io.Writer,
+) ([]*FileResult, error) {
+	results := r.ProcessFiles(ctx, files)
+	stale := 0
+	var diagnostics process.DiagnosticErrors
+	for _, result := range results {
+		if result.Err != nil {
+			err := r.keepGoing(&diagnostics, result.File, result.Err)
+			if err != nil {
+				return results, err
+			}
 		}
 
-		processed, procErr := r.processor.ProcessMarkdown(ctx, bytes)
-		if procErr != nil {
-			return fmt.Errorf("%w: processing file: %w", ErrRunner, procErr)
+		if result.Processed == nil || bytes.Equal(result.Original, result.Processed) {
+			continue
+		}
+		stale++
+		if out == nil {
+			continue
 		}
 
-		// #nosec G306
-		writeErr := os.WriteFile(file, processed, DefaultPermissions)
+		diff, diffErr := UnifiedDiff(result.File, result.Original, result.Processed)
+		if diffErr != nil {
+			return results, fmt.Errorf("%w: diffing file: %w", ErrRunner, diffErr)
+		}
+
+		_, writeErr := io.WriteString(out, diff)
 		if writeErr != nil {
-			return fmt.Errorf("%w: writing file: %w", ErrRunner, writeErr)
+			return results, fmt.Errorf("%w: writing diff: %w", ErrRunner, writeErr)
 		}
 	}
+
+	if len(diagnostics) > 0 {
+		return results, DiagnosticsError(diagnostics)
+	}
+
+	if stale > 0 {
+		return results, fmt.Errorf(
+			"%w: %w: %d file(s) out of date",
+			ErrRunner,
+			ErrStaleFiles,
+			stale,
+		)
+	}
+	return results, nil
+}
+
+// Filter reads markdown from in and writes the processed markdown to out.
+func (r *Runner) Filter(
+	ctx context.Context,
+	in io.Reader,
+	out io.Writer,
+) error {
+	original, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("%w: reading input: %w", ErrRunner, err)
+	}
+
+	var diagnostics process.DiagnosticErrors
+	processed, err := r.processor.ProcessMarkdownFile(ctx, StdinName, original)
+	if err != nil {
+		err = r.keepGoing(
+			&diagnostics,
+			StdinName,
+			fmt.Errorf("%w: processing input: %w", ErrRunner, err),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = out.Write(processed)
+	if err != nil {
+		return fmt.Errorf("%w: writing output: %w", ErrRunner, err)
+	}
+	return DiagnosticsError(diagnostics)
+}
+
+// ProcessFiles processes files using a pool of up to r.jobs workers. The
+// results are returned in the same order as files.
+func (r *Runner) ProcessFiles(
+	ctx context.Context,
+	files []string,
+) []*FileResult {
+	results := make([]*FileResult, len(files))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(r.jobs, len(files)) {
+		wg.Go(func() {
+			for i := range indexes {
+				results[i] = r.ProcessFile(ctx, files[i])
+			}
+		})
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// ProcessFile reads a markdown file and returns both its original and
+// processed contents.
+func (r *Runner) ProcessFile(ctx context.Context, file string) *FileResult {
+	result := &FileResult{File: file}
+	original, err := os.ReadFile(file)
+	if err != nil {
+		result.Err = fmt.Errorf("%w: reading file: %w", ErrRunner, err)
+		return result
+	}
+	result.Original = original
+
+	// When keeping going, the processed contents are returned along with the
+	// error, so hold on to them.
+	processed, results, err := r.processor.ProcessMarkdownFileWithResults(
+		ctx,
+		file,
+		original,
+	)
+	result.Processed = processed
+	result.Results = results
+	if err != nil {
+		result.Err = fmt.Errorf("%w: processing file: %w", ErrRunner, err)
+	}
+	return result
+}
+
+// keepGoing returns err as is if the runner stops at the first error.
+// Otherwise, it records err in diagnostics and returns nil so that the
+// caller carries on.
+func (r *Runner) keepGoing(
+	diagnostics *process.DiagnosticErrors,
+	file string,
+	err error,
+) error {
+	if !r.processor.KeepGoing() {
+		return err
+	}
+	*diagnostics = append(*diagnostics, process.ToDiagnosticErrors(file, err)...)
 	return nil
 }
 
+// WriteFile replaces the contents of file with data, keeping its mode. The
+// data is written to a temporary file which is then renamed over file, so
+// file is never left partially written. If file is a symlink, the file it
+// points to is replaced.
+func WriteFile(file string, data []byte) error {
+	path, err := filepath.EvalSymlinks(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		path = file
+	} else if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(DefaultPermissions)
+	info, err := os.Stat(path)
+	if err == nil {
+		perm = info.Mode().Perm()
+	}
+	return cache.WriteFileAtomicWithPerm(path, data, perm)
+}
+
+// DiagnosticsError returns nil if there are no diagnostics. Otherwise, it
+// returns the diagnostics wrapped in ErrRunner.
+func DiagnosticsError(diagnostics process.DiagnosticErrors) error {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrRunner, diagnostics)
+}
+
+func ListMarkdownFilesInDirs(
+	dirs []string,
+	opts ListOptions,
+) ([]string, error) {
+	files := []string{}
+	for _, dir := range dirs {
+		dirFiles, err := ListMarkdownFiles(dir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: listing markdown files: %w", ErrRunner, err)
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
 func ListMarkdownFiles(
 	dir string,
-	ignoreDirs []string,
+	opts ListOptions,
 ) ([]string, error) {
-	ignoreDirMap := make(map[string]bool)
-	for _, ignoreDir := range ignoreDirs {
-		ignoreDir = strings.TrimPrefix(ignoreDir, "./")
-		ignoreDir = strings.TrimSuffix(ignoreDir, "/")
-		ignoreDirMap[ignoreDir] = true
+	includePatterns := opts.Include
+	if len(includePatterns) == 0 {
+		includePatterns = []string{MarkdownPattern}
+	}
+
+	include, err := NewMatcher(includePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	exclude, err := NewMatcher(opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	root := FindRepoRoot(absDir)
+
+	// Ignore files above dir apply to it as well, as they would for git.
+	for _, parent := range ParentDirs(root, absDir) {
+		err = AddIgnoreFiles(exclude, parent, root, opts.Gitignore)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	files := []string{}
-	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		walkRel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel, err := RelPath(root, filepath.Join(absDir, walkRel))
 		if err != nil {
 			return err
 		}
 
 		if d.IsDir() {
-			if ignoreDirMap[d.Name()] {
+			if d.Name() == GitDirName {
+				return filepath.SkipDir
+			}
+			if walkRel != "." && exclude.Match(rel, true) {
 				return filepath.SkipDir
 			}
-			return nil
+
+			// Patterns in a directory's ignore files apply to everything
+			// beneath it, which WalkDir visits before moving on.
+			return AddIgnoreFiles(exclude, filepath.Join(absDir, walkRel), root, opts.Gitignore)
 		}
 
-		if filepath.Ext(path) == MarkdownExt {
+		if include.Match(rel, false) && !exclude.Match(rel, false) {
 			files = append(files, path)
 		}
 		return nil
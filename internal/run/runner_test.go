@@ -0,0 +1,284 @@
+package run_test
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tahardi/pluckmd/internal/cache"
+	"github.com/tahardi/pluckmd/internal/config"
+	"github.com/tahardi/pluckmd/internal/fetch"
+	"github.com/tahardi/pluckmd/internal/pluck"
+	"github.com/tahardi/pluckmd/internal/process"
+	"github.com/tahardi/pluckmd/internal/run"
+)
+
+const (
+	enclaveSEVYAMLFile = "enclave-sev.yaml"
+	markdownFile       = "README.md"
+)
+
+//go:embed testdata/enclave-sev.yaml
+var enclaveSEVYAML []byte
+
+//go:embed testdata/processed.md
+var processedMD []byte
+
+//go:embed testdata/unprocessed.md
+var unprocessedMD []byte
+
+func newTestRunner(t *testing.T, dir string, jobs int) *run.Runner {
+	t.Helper()
+	return newTestRunnerWithKeepGoing(t, dir, jobs, false)
+}
+
+func newTestRunnerWithKeepGoing(
+	t *testing.T,
+	dir string,
+	jobs int,
+	keepGoing bool,
+) *run.Runner {
+	t.Helper()
+
+	cacher, err := cache.NewRAMCacher()
+	require.NoError(t, err)
+
+	fetcher, err := fetch.NewLocalFetcherWithBaseDir(dir)
+	require.NoError(t, err)
+
+	yamlPlucker, err := pluck.NewYAMLPlucker()
+	require.NoError(t, err)
+
+	fetchers := []fetch.Fetcher{fetcher}
+	pluckers := map[pluck.Lang]pluck.Plucker{pluck.YAML: yamlPlucker}
+
+	runner, err := run.NewRunnerWithProcessor(
+		process.NewProcessorWithKeepGoing(cacher, fetchers, pluckers, keepGoing),
+		jobs,
+	)
+	require.NoError(t, err)
+	return runner
+}
+
+func writeTestDir(t *testing.T, md []byte, mdFiles ...string) string {
+	t.Helper()
+
+	if len(mdFiles) == 0 {
+		mdFiles = []string{markdownFile}
+	}
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, enclaveSEVYAMLFile), enclaveSEVYAML, 0600)
+	require.NoError(t, err)
+	for _, mdFile := range mdFiles {
+		err = os.WriteFile(filepath.Join(dir, mdFile), md, 0600)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestRunner_Run(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+
+		files := []string{filepath.Join(dir, markdownFile)}
+
+		// when
+		_, err := runner.Run(ctx, files)
+
+		// then
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(dir, markdownFile))
+		require.NoError(t, err)
+		assert.Equal(t, processedMD, got)
+	})
+
+	t.Run("happy path - parallel jobs", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		mdFiles := []string{"a.md", "b.md", "c.md", "d.md", "e.md"}
+		dir := writeTestDir(t, unprocessedMD, mdFiles...)
+		runner := newTestRunner(t, dir, 3)
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+
+		// when
+		_, err = runner.Run(ctx, files)
+
+		// then
+		require.NoError(t, err)
+		for _, mdFile := range mdFiles {
+			got, readErr := os.ReadFile(filepath.Join(dir, mdFile))
+			require.NoError(t, readErr)
+			assert.Equal(t, processedMD, got)
+		}
+	})
+
+	t.Run("happy path - keeps mode and skips unchanged files", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		stale := filepath.Join(dir, markdownFile)
+		require.NoError(t, os.Chmod(stale, 0750))
+		current := filepath.Join(dir, "current.md")
+		require.NoError(t, os.WriteFile(current, processedMD, 0600))
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, os.Chtimes(current, past, past))
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+
+		// when
+		_, err := runner.Run(ctx, []string{stale, current})
+
+		// then
+		require.NoError(t, err)
+		info, err := os.Stat(stale)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+		info, err = os.Stat(current)
+		require.NoError(t, err)
+		assert.True(t, past.Equal(info.ModTime()))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+
+	t.Run("error - keep going", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		broken := []byte(strings.Replace(
+			string(unprocessedMD),
+			"enclave.args",
+			"enclave.missing",
+			1,
+		))
+		dir := writeTestDir(t, unprocessedMD, "a.md", "c.md")
+		brokenFile := filepath.Join(dir, "b.md")
+		require.NoError(t, os.WriteFile(brokenFile, broken, 0600))
+		runner := newTestRunnerWithKeepGoing(t, dir, 2, true)
+		files, err := run.ListMarkdownFiles(dir, run.ListOptions{})
+		require.NoError(t, err)
+
+		// when
+		_, err = runner.Run(ctx, files)
+
+		// then
+		var diagnostics process.DiagnosticErrors
+		require.ErrorAs(t, err, &diagnostics)
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, brokenFile, diagnostics[0].File)
+		assert.Equal(t, 3, diagnostics[0].Line)
+
+		for _, mdFile := range []string{"a.md", "c.md"} {
+			got, readErr := os.ReadFile(filepath.Join(dir, mdFile))
+			require.NoError(t, readErr)
+			assert.Equal(t, processedMD, got)
+		}
+		got, err := os.ReadFile(brokenFile)
+		require.NoError(t, err)
+		assert.Equal(t, broken, got)
+	})
+
+	t.Run("error - invalid jobs", func(t *testing.T) {
+		// when
+		_, err := run.NewRunnerWithProcessor(nil, 0)
+
+		// then
+		require.ErrorIs(t, err, run.ErrInvalidJobs)
+	})
+}
+
+func TestRunner_Check(t *testing.T) {
+	t.Run("happy path - up to date", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, processedMD)
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		files := []string{filepath.Join(dir, markdownFile)}
+		var out bytes.Buffer
+
+		// when
+		_, err := runner.Check(ctx, files, &out)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("error - stale file", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		files := []string{filepath.Join(dir, markdownFile)}
+		var out bytes.Buffer
+
+		// when
+		_, err := runner.Check(ctx, files, &out)
+
+		// then
+		require.ErrorIs(t, err, run.ErrStaleFiles)
+		assert.Contains(t, out.String(), "+++ b/"+filepath.Join(dir, markdownFile))
+		assert.Contains(t, out.String(), "+  domain: \"bearclave.tee\"")
+
+		got, err := os.ReadFile(filepath.Join(dir, markdownFile))
+		require.NoError(t, err)
+		assert.Equal(t, unprocessedMD, got)
+	})
+}
+
+func TestRunner_Filter(t *testing.T) {
+	t.Run("happy path", func(t *testing.T) {
+		// given
+		ctx := context.Background()
+		dir := writeTestDir(t, unprocessedMD)
+		runner := newTestRunner(t, dir, config.DefaultJobs)
+		var out bytes.Buffer
+
+		// when
+		err := runner.Filter(ctx, bytes.NewReader(unprocessedMD), &out)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, processedMD, out.Bytes())
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Run("happy path - symlink", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		target := filepath.Join(dir, "target.md")
+		require.NoError(t, os.WriteFile(target, []byte("old"), 0600))
+		link := filepath.Join(dir, "link.md")
+		require.NoError(t, os.Symlink(target, link))
+
+		// when
+		err := run.WriteFile(link, []byte("new"))
+
+		// then
+		require.NoError(t, err)
+		got, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(got))
+
+		info, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&os.ModeSymlink)
+
+		info, err = os.Stat(target)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+}
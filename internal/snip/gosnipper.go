@@ -1,11 +1,14 @@
 package snip
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -13,38 +16,85 @@ const (
 	EllipsesLine = "\t// ...\n"
 	OpeningBrace = "{\n"
 	ClosingBrace = "}\n"
+
+	// MarkerStart and MarkerEnd are the kinds of marker comments. See
+	// MarkerRegex.
+	MarkerStart = "start"
+	MarkerEnd   = "end"
+
+	// Go snippets are wrapped in these to make them valid Go files for the
+	// parser. See ParseGoSnippet.
+	dummyPackage   = "package dummy\n"
+	dummyStruct    = dummyPackage + "type dummy struct {\n"
+	dummyInterface = dummyPackage + "type dummy interface {\n"
+	dummyClosing   = "\n}\n"
 )
 
 var (
 	ErrGoSnipper            = errors.New("go snipper")
 	ErrOpeningBraceNotFound = errors.New("finding opening brace")
+	ErrMarkerNotFound       = errors.New("marker not found")
+	ErrUnclosedMarker       = errors.New("unclosed marker")
+
+	Braces = Delimiters{Opening: OpeningBrace, Closing: ClosingBrace}
+
+	// MarkerRegex matches the "// pluck:start name" and "// pluck:end name"
+	// comments that mark the lines in between as a part of the body that
+	// can be selected by name. Marker comments are never shown.
+	MarkerRegex = regexp.MustCompile(`^\s*//\s*pluck:(` + MarkerStart + `|` + MarkerEnd + `)\s+(\S+)\s*$`)
 )
 
+// Delimiters enclose the body of a snippet, which ranges select lines from,
+// e.g., the braces of a function or the parentheses of a const group. A
+// snippet without delimiters, such as a single const, has no body and is
+// always rendered in full.
+type Delimiters struct {
+	Opening string
+	Closing string
+}
+
 type GoSnipper struct {
 	name       string
 	definition string
 	body       string
+	delimiters Delimiters
+	rawLines   []string
+	skipped    int
+	visible    []int
 	bodyLines  []string
 	length     int
 }
 
 func NewGoSnipper(name string, snippet string) (*GoSnipper, error) {
-	definition, body, err := ParseGoSnippet(snippet)
+	definition, body, delimiters, err := ParseGoSnippet(snippet)
 	if err != nil {
 		return nil, fmt.Errorf("%w: parsing snippet: %w", ErrGoSnipper, err)
 	}
-	return NewGoSnipperWithDefinitionAndBody(name, definition, body)
+	return NewGoSnipperWithDelimiters(name, definition, body, delimiters)
 }
 
 func NewGoSnipperWithDefinitionAndBody(
 	name string,
 	definition string,
 	body string,
+) (*GoSnipper, error) {
+	return NewGoSnipperWithDelimiters(name, definition, body, Braces)
+}
+
+func NewGoSnipperWithDelimiters(
+	name string,
+	definition string,
+	body string,
+	delimiters Delimiters,
 ) (*GoSnipper, error) {
 	return &GoSnipper{
 		name:       name,
 		definition: definition,
 		body:       body,
+		delimiters: delimiters,
+		rawLines:   nil,
+		skipped:    0,
+		visible:    nil,
 		bodyLines:  nil,
 		length:     0,
 	}, nil
@@ -62,32 +112,27 @@ func (g *GoSnipper) Body() string {
 	return g.body
 }
 
+func (g *GoSnipper) Delimiters() Delimiters {
+	return g.delimiters
+}
+
 func (g *GoSnipper) Full() string {
-	return g.definition + OpeningBrace + g.body + ClosingBrace
+	return g.definition + g.delimiters.Opening + StripMarkers(g.body) + g.delimiters.Closing
 }
 
 func (g *GoSnipper) Empty() string {
-	return g.definition + OpeningBrace + EllipsesLine + ClosingBrace
+	if g.delimiters == (Delimiters{}) {
+		return g.Full()
+	}
+	return g.definition + g.delimiters.Opening + EllipsesLine + g.delimiters.Closing
 }
 
 func (g *GoSnipper) Snippet(start int, end int) (string, error) {
-	// Lazy initialization of bodyLines. Sometimes we end up with empty lines
-	// at the beginning and ending of the body. If so, remove them.
-	if g.bodyLines == nil {
-		lines := strings.Split(g.body, "\n")
-		if len(lines) > 0 {
-			if lines[0] == "" {
-				lines = lines[1:]
-			}
-			if lines[len(lines)-1] == "" {
-				lines = lines[:len(lines)-1]
-			}
-		}
-		g.bodyLines = lines
-		g.length = len(lines)
-	}
+	g.splitBody()
 
 	switch {
+	case g.delimiters == (Delimiters{}):
+		return g.Full(), nil
 	case start == EmptyStart && end == EmptyEnd:
 		return g.Empty(), nil
 	case start == FullStart && end == FullEnd:
@@ -100,81 +145,405 @@ func (g *GoSnipper) Snippet(start int, end int) (string, error) {
 			end,
 		)
 	}
+	return g.render([]Range{{Start: start, End: end}}), nil
+}
+
+// SnippetWithSelection renders the parts of the body that selection selects.
+// Line ranges count lines without marker comments.
+func (g *GoSnipper) SnippetWithSelection(selection Selection) (string, error) {
+	g.splitBody()
+	if g.delimiters == (Delimiters{}) {
+		return g.Full(), nil
+	}
+
+	ranges, err := g.Resolve(selection)
+	if err != nil {
+		return "", err
+	}
+	return g.render(ranges), nil
+}
+
+// Resolve returns the ranges of body lines that selection selects.
+func (g *GoSnipper) Resolve(selection Selection) ([]Range, error) {
+	ranges := make([]Range, 0, len(selection.Lines))
+	for _, r := range selection.Lines {
+		if r.Start < 0 || r.Start > r.End || r.End > g.length {
+			return nil, fmt.Errorf(
+				"%w: invalid range [start: %d, end: %d)",
+				ErrGoSnipper,
+				r.Start,
+				r.End,
+			)
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(selection.Statements) > 0 {
+		statements, err := FindStatementLines(
+			g.definition + g.delimiters.Opening + g.body + g.delimiters.Closing,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: finding statements: %w", ErrGoSnipper, err)
+		}
+		for _, r := range selection.Statements {
+			if r.Start < 0 || r.Start >= r.End || r.End > len(statements) {
+				return nil, fmt.Errorf(
+					"%w: invalid statements [start: %d, end: %d) of %d",
+					ErrGoSnipper,
+					r.Start,
+					r.End,
+					len(statements),
+				)
+			}
+			ranges = append(ranges, g.visibleRange(Range{
+				Start: statements[r.Start].Start - g.skipped,
+				End:   statements[r.End-1].End - g.skipped,
+			}))
+		}
+	}
+
+	for _, name := range selection.Markers {
+		marked, err := g.FindMarkers(name)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, marked...)
+	}
+	return ranges, nil
+}
+
+// FindMarkers returns the ranges of body lines between every pair of marker
+// comments called name.
+func (g *GoSnipper) FindMarkers(name string) ([]Range, error) {
+	g.splitBody()
+
+	var ranges []Range
+	start := -1
+	for i, line := range g.rawLines {
+		marker := MarkerRegex.FindStringSubmatch(line)
+		if marker == nil || marker[2] != name {
+			continue
+		}
+		switch {
+		case marker[1] == MarkerStart && start == -1:
+			start = i + 1
+		case marker[1] == MarkerEnd && start != -1:
+			ranges = append(ranges, g.visibleRange(Range{Start: start, End: i}))
+			start = -1
+		}
+	}
+
+	switch {
+	case start != -1:
+		return nil, fmt.Errorf("%w: %w: %s", ErrGoSnipper, ErrUnclosedMarker, name)
+	case len(ranges) == 0:
+		return nil, fmt.Errorf("%w: %w: %s", ErrGoSnipper, ErrMarkerNotFound, name)
+	}
+	return ranges, nil
+}
+
+// StripMarkers removes the lines of marker comments from text.
+func StripMarkers(text string) string {
+	if !strings.Contains(text, "pluck:") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !MarkerRegex.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// splitBody lazily splits the body into lines. Sometimes we end up with empty
+// lines at the beginning and ending of the body. If so, remove them. Marker
+// comments stay in rawLines, but bodyLines, which ranges select from, does
+// not have them.
+func (g *GoSnipper) splitBody() {
+	if g.bodyLines != nil {
+		return
+	}
+
+	lines := strings.Split(g.body, "\n")
+	if lines[0] == "" {
+		lines = lines[1:]
+		g.skipped = 1
+	}
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	g.rawLines = lines
+	g.visible = make([]int, 0, len(lines)+1)
+	g.bodyLines = make([]string, 0, len(lines))
+	for _, line := range lines {
+		g.visible = append(g.visible, len(g.bodyLines))
+		if !MarkerRegex.MatchString(line) {
+			g.bodyLines = append(g.bodyLines, line)
+		}
+	}
+	g.visible = append(g.visible, len(g.bodyLines))
+	g.length = len(g.bodyLines)
+}
+
+// visibleRange converts a range of rawLines into a range of bodyLines.
+func (g *GoSnipper) visibleRange(raw Range) Range {
+	start := min(max(raw.Start, 0), len(g.rawLines))
+	end := min(max(raw.End, start), len(g.rawLines))
+	return Range{Start: g.visible[start], End: g.visible[end]}
+}
+
+// render writes the definition and the ranges of body lines, in order. If we
+// are skipping part of the body, an Ellipses line indicates that there is
+// hidden code we are not including.
+func (g *GoSnipper) render(ranges []Range) string {
+	slices.SortFunc(ranges, func(a, b Range) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
 
-	// If we are skipping the beginning of the body, add an Ellipses line to
-	// indicate that there is hidden code we are not including.
 	var snippet strings.Builder
 	snippet.WriteString(g.Definition())
-	snippet.WriteString(OpeningBrace)
-	if start != 0 {
+	snippet.WriteString(g.delimiters.Opening)
+
+	next := 0
+	for _, r := range ranges {
+		if r.Start > next {
+			snippet.WriteString(EllipsesLine)
+		}
+		for i := max(r.Start, next); i < r.End; i++ {
+			snippet.WriteString(g.bodyLines[i])
+			snippet.WriteString("\n")
+		}
+		next = max(next, r.End)
+	}
+
+	if next != g.length {
 		snippet.WriteString(EllipsesLine)
 	}
+	snippet.WriteString(g.delimiters.Closing)
+	return snippet.String()
+}
 
-	for i := start; i < end; i++ {
-		snippet.WriteString(g.bodyLines[i])
-		snippet.WriteString("\n")
+// ParseGoSnippet splits snippet into the definition in front of its body, the
+// body, and the delimiters around the body. Snippets without a body, such as
+// a const declaration that is not a group, a type without braces, or a struct
+// field or interface method whose type has none, are all definition. So is
+// a doc comment in front of the declaration, which is thus kept when the body
+// is elided.
+func ParseGoSnippet(snippet string) (string, string, Delimiters, error) {
+	fset, prefix, body, err := ParseGoBody(snippet)
+	if err != nil {
+		return "", "", Delimiters{}, err
+	}
+	if body == nil {
+		return snippet, "", Delimiters{}, nil
 	}
 
-	// If we are skipping the end of the body, add an Ellipses line to indicate
-	// that there is hidden code we are not including.
-	if end != g.length {
-		snippet.WriteString(EllipsesLine)
+	// Calculate offsets relative to the original code string, i.e., without
+	// the prefix we wrapped it in.
+	opening, closing := BodyDelimiters(body)
+	offset := fset.Position(opening).Offset - len(prefix)
+	endOffset := fset.Position(closing).Offset - len(prefix)
+
+	definition := snippet[:offset]
+	bodyText := strings.TrimPrefix(snippet[offset+1:endOffset], "\n")
+	delimiters := Delimiters{
+		Opening: snippet[offset:offset+1] + "\n",
+		Closing: strings.TrimSuffix(snippet[endOffset:], "\n") + "\n",
 	}
-	snippet.WriteString(ClosingBrace)
-	return snippet.String(), nil
+	return definition, bodyText, delimiters, nil
 }
 
-func ParseGoSnippet(snippet string) (string, string, error) {
+// ParseGoBody parses snippet, which is a declaration or a member of a struct
+// or interface, and returns the node of its body, or nil if it has none. See
+// FindDeclBody and FindMemberBody. Positions are relative to snippet wrapped
+// in the returned prefix.
+func ParseGoBody(snippet string) (*token.FileSet, string, ast.Node, error) {
 	fset := token.NewFileSet()
 
 	// Wrap snippet in a package to make it a valid Go file for the parser
-	dummyPackage := "package dummy\n"
-	dummySource := dummyPackage + snippet
-	f, err := parser.ParseFile(fset, "", dummySource, 0)
-	if err != nil {
-		return "", "", fmt.Errorf("making ast file: %w", err)
+	prefix := dummyPackage
+	f, err := parser.ParseFile(fset, "", prefix+snippet, 0)
+	if err == nil {
+		body, err := FindDeclBody(fset, f)
+		return fset, prefix, body, err
 	}
 
-	var openingBracePos token.Pos
-	var closingBracePos token.Pos
+	// The snippet may be a member of a struct or interface instead.
+	prefix, member := ParseGoMember(fset, snippet)
+	if member == nil {
+		return nil, "", nil, fmt.Errorf("making ast file: %w", err)
+	}
+	if fields := FindMemberBody(fset, member); fields != nil {
+		return fset, prefix, fields, nil
+	}
+	return fset, prefix, nil, nil
+}
+
+// FindDeclBody returns the body of the first declaration in f: the block of a
+// function, the parenthesised group of a const or var declaration, or the
+// fields or methods of a type. It is nil if the declaration has no body,
+// e.g., a const or var declaration that is not a group, a function without a
+// body, or a type without braces such as "type Kind string". See TypeBody.
+func FindDeclBody(fset *token.FileSet, f *ast.File) (ast.Node, error) {
+	var body ast.Node
+	found := false
 
-	// Find the first function or type declaration
+	// Find the first function, type, const, or var declaration
 	ast.Inspect(f, func(n ast.Node) bool {
 		switch x := n.(type) {
+		case *ast.GenDecl:
+			if x.Tok != token.CONST && x.Tok != token.VAR {
+				return true
+			}
+			if x.Lparen.IsValid() {
+				body = x
+			}
+			found = true
 		case *ast.FuncDecl:
 			if x.Body != nil {
-				openingBracePos = x.Body.Lbrace
-				closingBracePos = x.Body.Rbrace
-				return false
+				body = x.Body
 			}
+			found = true
 		case *ast.TypeSpec:
-			if st, ok := x.Type.(*ast.StructType); ok {
-				openingBracePos = st.Fields.Opening
-				closingBracePos = st.Fields.Closing
-				return false
-			}
-			if it, ok := x.Type.(*ast.InterfaceType); ok {
-				openingBracePos = it.Methods.Opening
-				closingBracePos = it.Methods.Closing
-				return false
+			if fields := TypeBody(fset, x.Type); fields != nil {
+				body = fields
 			}
+			found = true
+		}
+		return !found
+	})
+
+	if !found {
+		return nil, ErrOpeningBraceNotFound
+	}
+	return body, nil
+}
+
+// TypeBody returns the fields or methods of the first struct or interface type
+// in expr, which may be nested inside another type, e.g., the element type of
+// a slice or the value type of a map. It is nil if there is none, or if the
+// braces are on the same line, since there are no lines to select from then.
+// Function types have no body, even if they take or return a struct.
+func TypeBody(fset *token.FileSet, expr ast.Expr) *ast.FieldList {
+	var fields *ast.FieldList
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.StructType:
+			fields = t.Fields
+		case *ast.InterfaceType:
+			fields = t.Methods
+		case *ast.FuncType:
+			return false
 		}
-		return true
+		return fields == nil
 	})
 
-	if !openingBracePos.IsValid() {
-		return "", "", ErrOpeningBraceNotFound
+	if fields == nil ||
+		fset.Position(fields.Opening).Line == fset.Position(fields.Closing).Line {
+		return nil
 	}
+	return fields
+}
 
-	// Calculate offsets relative to the original code string
-	// Subtract 1 because we added "package dummy\n" (14 chars)
-	offset := fset.Position(openingBracePos).Offset - len(dummyPackage)
-	endOffset := fset.Position(closingBracePos).Offset - len(dummyPackage)
+// BodyDelimiters returns the positions of the delimiters around body.
+func BodyDelimiters(body ast.Node) (token.Pos, token.Pos) {
+	switch b := body.(type) {
+	case *ast.BlockStmt:
+		return b.Lbrace, b.Rbrace
+	case *ast.GenDecl:
+		return b.Lparen, b.Rparen
+	case *ast.FieldList:
+		return b.Opening, b.Closing
+	default:
+		return token.NoPos, token.NoPos
+	}
+}
 
-	definition := snippet[:offset]
-	body := snippet[offset+1 : endOffset]
-	if body[0] == '\n' {
-		body = body[1:]
+// BodyElements returns the top-level elements of body: the statements of a
+// block, the specs of a group, or the fields or methods of a type.
+func BodyElements(body ast.Node) []ast.Node {
+	var elements []ast.Node
+	switch b := body.(type) {
+	case *ast.BlockStmt:
+		for _, stmt := range b.List {
+			elements = append(elements, stmt)
+		}
+	case *ast.GenDecl:
+		for _, spec := range b.Specs {
+			elements = append(elements, spec)
+		}
+	case *ast.FieldList:
+		for _, field := range b.List {
+			elements = append(elements, field)
+		}
+	}
+	return elements
+}
+
+// FindStatementLines returns the lines of the top-level statements of the
+// body of snippet, counted from the line after the opening delimiter. See
+// BodyElements.
+func FindStatementLines(snippet string) ([]Range, error) {
+	fset, _, body, err := ParseGoBody(snippet)
+	if err != nil {
+		return nil, err
 	}
-	return definition, body, nil
+	if body == nil {
+		return nil, nil
+	}
+
+	opening, _ := BodyDelimiters(body)
+	first := fset.Position(opening).Line + 1
+	elements := BodyElements(body)
+	lines := make([]Range, 0, len(elements))
+	for _, element := range elements {
+		lines = append(lines, Range{
+			Start: fset.Position(element.Pos()).Line - first,
+			End:   fset.Position(element.End()).Line - first + 1,
+		})
+	}
+	return lines, nil
+}
+
+// ParseGoMember parses snippet as a struct field or an interface method. It
+// returns the member and the prefix that snippet was wrapped in to parse it,
+// or nil if snippet is neither.
+func ParseGoMember(fset *token.FileSet, snippet string) (string, *ast.Field) {
+	for _, prefix := range []string{dummyStruct, dummyInterface} {
+		f, err := parser.ParseFile(fset, "", prefix+snippet+dummyClosing, 0)
+		if err != nil || len(f.Decls) != 1 {
+			continue
+		}
+
+		genDecl, ok := f.Decls[0].(*ast.GenDecl)
+		if !ok || len(genDecl.Specs) != 1 {
+			continue
+		}
+		typeSpec, ok := genDecl.Specs[0].(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+
+		var members *ast.FieldList
+		switch t := typeSpec.Type.(type) {
+		case *ast.StructType:
+			members = t.Fields
+		case *ast.InterfaceType:
+			members = t.Methods
+		}
+		if members != nil && len(members.List) == 1 {
+			return prefix, members.List[0]
+		}
+	}
+	return "", nil
+}
+
+// FindMemberBody returns the fields or methods of the struct or interface type
+// of member, or nil if it has none. See TypeBody.
+func FindMemberBody(fset *token.FileSet, member *ast.Field) *ast.FieldList {
+	return TypeBody(fset, member.Type)
 }
@@ -187,3 +187,334 @@ func TestGoSnipper_Snippet(t *testing.T) {
 		assert.Equal(t, want, got)
 	})
 }
+
+func TestGoSnipper_Snippet_Group(t *testing.T) {
+	group := "const (\n\tType Kind = iota\n\tFunc\n\tNode\n)\n"
+	tests := []struct {
+		name  string
+		start int
+		end   int
+		want  string
+	}{
+		{name: "full", start: snip.FullStart, end: snip.FullEnd, want: group},
+		{name: "empty", start: snip.EmptyStart, end: snip.EmptyEnd, want: "const (\n" + snip.EllipsesLine + ")\n"},
+		{name: "range", start: 0, end: 2, want: "const (\n\tType Kind = iota\n\tFunc\n" + snip.EllipsesLine + ")\n"},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// given
+			snipper, err := snip.NewGoSnipper("(Type)", group)
+			require.NoError(t, err)
+
+			// when
+			got, err := snipper.Snippet(tt.start, tt.end)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("happy path - single spec is always full", func(t *testing.T) {
+		// given
+		spec := "var ErrKind = errors.New(\"kind\")\n"
+		snipper, err := snip.NewGoSnipper("ErrKind", spec)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.Snippet(0, 1)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, spec, got)
+	})
+}
+
+func TestGoSnipper_Snippet_Member(t *testing.T) {
+	t.Run("happy path - nested struct", func(t *testing.T) {
+		// given
+		member := "// cache configures caching.\ncache struct {\n\tDir string\n\tTTL int\n} `yaml:\"cache\"`\n"
+		want := "// cache configures caching.\ncache struct {\n\tDir string\n" + snip.EllipsesLine + "} `yaml:\"cache\"`\n"
+		snipper, err := snip.NewGoSnipper("Config.cache", member)
+		require.NoError(t, err)
+
+		// when
+		full, err := snipper.Snippet(snip.FullStart, snip.FullEnd)
+		require.NoError(t, err)
+		got, err := snipper.Snippet(0, 1)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, member, full)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("happy path - interface method is always full", func(t *testing.T) {
+		// given
+		member := "// Retrieve returns data.\nRetrieve(ctx context.Context, uri string) ([]byte, error)\n"
+		snipper, err := snip.NewGoSnipper("Cacher.Retrieve", member)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.Snippet(snip.EmptyStart, snip.EmptyEnd)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, member, got)
+	})
+}
+
+func TestGoSnipper_Snippet_Doc(t *testing.T) {
+	t.Run("happy path - keeps doc comment when eliding", func(t *testing.T) {
+		// given
+		doc := "// Config configures a run, e.g., Config{Timeout: 1}.\n"
+		snippet := doc + "type Config struct {\n\tTimeout int\n\tCacheDir string\n}\n"
+		want := doc + "type Config struct {\n\tTimeout int\n" + snip.EllipsesLine + "}\n"
+		snipper, err := snip.NewGoSnipper("Config", snippet)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.Snippet(0, 1)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("happy path - keeps doc comment when empty", func(t *testing.T) {
+		// given
+		doc := "// Run runs {everything}.\n"
+		snippet := doc + "func Run() {\n\treturn\n}\n"
+		snipper, err := snip.NewGoSnipper("Run", snippet)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.Snippet(snip.EmptyStart, snip.EmptyEnd)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, doc+"func Run() {\n"+snip.EllipsesLine+"}\n", got)
+	})
+}
+
+func TestGoSnipper_Snippet_Type(t *testing.T) {
+	braceless := []struct {
+		name    string
+		snippet string
+	}{
+		{name: "Kind", snippet: "type Kind string\n"},
+		{name: "Alias", snippet: "type Alias = pluck.Kind\n"},
+		{name: "Handler", snippet: "type Handler func(cfg struct {\n\tTimeout int\n}) error\n"},
+		{name: "Number", snippet: "type Number interface{ ~int | ~float64 }\n"},
+		{name: "Pair", snippet: "type Pair[K comparable, V any] struct{ Key K; Value V }\n"},
+		{name: "Set", snippet: "type Set[T interface {\n\tcomparable\n}] []T\n"},
+	}
+	ranges := [][2]int{{snip.FullStart, snip.FullEnd}, {snip.EmptyStart, snip.EmptyEnd}, {0, 1}}
+	for _, tt := range braceless {
+		t.Run("happy path - braceless "+tt.name, func(t *testing.T) {
+			// given
+			snipper, err := snip.NewGoSnipper(tt.name, tt.snippet)
+			require.NoError(t, err)
+
+			for _, r := range ranges {
+				// when
+				got, err := snipper.Snippet(r[0], r[1])
+
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, tt.snippet, got)
+			}
+		})
+	}
+
+	nested := []struct {
+		name    string
+		snippet string
+		start   int
+		end     int
+		want    string
+	}{
+		{
+			name:    "map of structs",
+			snippet: "type Handlers map[string]struct {\n\tName string\n\tRun  func()\n}\n",
+			start:   0,
+			end:     1,
+			want:    "type Handlers map[string]struct {\n\tName string\n" + snip.EllipsesLine + "}\n",
+		},
+		{
+			name:    "slice of interfaces",
+			snippet: "type Plucks []interface {\n\tPluck() string\n}\n",
+			start:   snip.EmptyStart,
+			end:     snip.EmptyEnd,
+			want:    "type Plucks []interface {\n" + snip.EllipsesLine + "}\n",
+		},
+		{
+			name:    "constraint in type parameters",
+			snippet: "type Set[T interface{ comparable }] struct {\n\titems []T\n\tsize  int\n}\n",
+			start:   1,
+			end:     2,
+			want:    "type Set[T interface{ comparable }] struct {\n" + snip.EllipsesLine + "\tsize  int\n}\n",
+		},
+		{
+			name:    "generic constraint",
+			snippet: "type Number interface {\n\t~int | ~float64\n}\n",
+			start:   snip.EmptyStart,
+			end:     snip.EmptyEnd,
+			want:    "type Number interface {\n" + snip.EllipsesLine + "}\n",
+		},
+	}
+	for _, tt := range nested {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// given
+			snipper, err := snip.NewGoSnipper(tt.name, tt.snippet)
+			require.NoError(t, err)
+
+			// when
+			got, err := snipper.Snippet(tt.start, tt.end)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGoSnipper_SnippetWithSelection(t *testing.T) {
+	const run = `func Run(ctx context.Context) error {
+	cfg := load()
+	// pluck:start exec
+	cmd := exec.Command("pluck")
+	err := cmd.Run()
+	// pluck:end exec
+	if err != nil {
+		return err
+	}
+	return nil
+}`
+	const definition = "func Run(ctx context.Context) error {\n"
+
+	tests := []struct {
+		name      string
+		selection snip.Selection
+		want      string
+	}{
+		{
+			name:      "markers",
+			selection: snip.Selection{Markers: []string{"exec"}},
+			want: definition + snip.EllipsesLine +
+				"\tcmd := exec.Command(\"pluck\")\n\terr := cmd.Run()\n" +
+				snip.EllipsesLine + "}\n",
+		},
+		{
+			name:      "statements",
+			selection: snip.Selection{Statements: []snip.Range{{Start: 3, End: 4}}},
+			want: definition + snip.EllipsesLine +
+				"\tif err != nil {\n\t\treturn err\n\t}\n" +
+				snip.EllipsesLine + "}\n",
+		},
+		{
+			name:      "several line ranges",
+			selection: snip.Selection{Lines: []snip.Range{{Start: 6, End: 7}, {Start: 0, End: 1}}},
+			want:      definition + "\tcfg := load()\n" + snip.EllipsesLine + "\treturn nil\n}\n",
+		},
+		{
+			name: "statements and markers",
+			selection: snip.Selection{
+				Statements: []snip.Range{{Start: 0, End: 1}, {Start: 4, End: 5}},
+				Markers:    []string{"exec"},
+			},
+			want: definition + "\tcfg := load()\n" +
+				"\tcmd := exec.Command(\"pluck\")\n\terr := cmd.Run()\n" +
+				snip.EllipsesLine + "\treturn nil\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run("happy path - "+tt.name, func(t *testing.T) {
+			// given
+			snipper, err := snip.NewGoSnipper("Run", run)
+			require.NoError(t, err)
+
+			// when
+			got, err := snipper.SnippetWithSelection(tt.selection)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("happy path - full snippet strips markers", func(t *testing.T) {
+		// given
+		snipper, err := snip.NewGoSnipper("Run", run)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.Snippet(snip.FullStart, snip.FullEnd)
+
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, got, "pluck:")
+		assert.Contains(t, got, "\tcfg := load()\n\tcmd := exec.Command(\"pluck\")\n")
+	})
+
+	t.Run("happy path - statements of a group", func(t *testing.T) {
+		// given
+		group := "const (\n\tType Kind = iota\n\n\t// Func is a function.\n\tFunc\n\tNode\n)\n"
+		snipper, err := snip.NewGoSnipper("(Func)", group)
+		require.NoError(t, err)
+
+		// when
+		got, err := snipper.SnippetWithSelection(snip.Selection{
+			Statements: []snip.Range{{Start: 1, End: 2}},
+		})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "const (\n"+snip.EllipsesLine+"\tFunc\n"+snip.EllipsesLine+")\n", got)
+	})
+
+	errorTests := []struct {
+		name      string
+		snippet   string
+		selection snip.Selection
+		wantErr   error
+	}{
+		{
+			name:      "marker not found",
+			snippet:   run,
+			selection: snip.Selection{Markers: []string{"fetch"}},
+			wantErr:   snip.ErrMarkerNotFound,
+		},
+		{
+			name:      "unclosed marker",
+			snippet:   "func Run() {\n\t// pluck:start exec\n\trun()\n}\n",
+			selection: snip.Selection{Markers: []string{"exec"}},
+			wantErr:   snip.ErrUnclosedMarker,
+		},
+		{
+			name:      "statements out of range",
+			snippet:   run,
+			selection: snip.Selection{Statements: []snip.Range{{Start: 4, End: 6}}},
+			wantErr:   snip.ErrGoSnipper,
+		},
+		{
+			name:      "lines out of range",
+			snippet:   run,
+			selection: snip.Selection{Lines: []snip.Range{{Start: 0, End: 8}}},
+			wantErr:   snip.ErrGoSnipper,
+		},
+	}
+	for _, tt := range errorTests {
+		t.Run("error - "+tt.name, func(t *testing.T) {
+			// given
+			snipper, err := snip.NewGoSnipper("Run", tt.snippet)
+			require.NoError(t, err)
+
+			// when
+			_, err = snipper.SnippetWithSelection(tt.selection)
+
+			// then
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
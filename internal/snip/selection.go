@@ -0,0 +1,23 @@
+package snip
+
+// Range selects the lines, or statements, [Start, End) of a snippet body.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Selection selects several parts of a snippet body at once: line ranges,
+// ranges of the top-level statements of the body, and the lines between
+// marker comments with the given names. The parts are shown in the order of
+// the body, and the hidden code between them is replaced with an ellipsis.
+type Selection struct {
+	Lines      []Range
+	Statements []Range
+	Markers    []string
+}
+
+// SelectionSnipper is a Snipper that can also render a Selection.
+type SelectionSnipper interface {
+	Snipper
+	SnippetWithSelection(selection Selection) (string, error)
+}